@@ -17,9 +17,14 @@ func main() {
 		log.Fatalf("配置初始化失败: %v", err)
 	}
 
-	// 加载动态订阅
+	// 加载动态订阅，文件损坏时停止启动，避免之后的修改覆盖原有订阅源
 	if err := config.LoadDynamicSubscribe(); err != nil {
-		log.Printf("加载动态订阅失败: %v", err)
+		log.Fatalf("加载动态订阅失败: %v", err)
+	}
+
+	// 加载用户列表，文件损坏时停止启动，避免之后的修改覆盖原有用户
+	if err := config.LoadUsers(); err != nil {
+		log.Fatalf("加载用户列表失败: %v", err)
 	}
 
 	// 启动订阅文件监视
@@ -27,25 +32,72 @@ func main() {
 		log.Printf("启动订阅文件监视失败: %v", err)
 	}
 
-	// 创建处理器
-	h := handler.NewHandler(&config.GlobalConfig)
+	// 创建处理器，分享链接记录损坏时停止启动，避免撤销列表被覆盖
+	h, err := handler.NewHandler(&config.GlobalConfig)
+	if err != nil {
+		log.Fatalf("创建处理器失败: %v", err)
+	}
 
 	// 设置路由
 	gin.SetMode(gin.ReleaseMode)
 	r := gin.Default()
 
-	// API路由组
-	api := r.Group("/api")
+	// API路由组，需要管理凭据，订阅令牌只能获取订阅
+	if config.GlobalConfig.AdminToken == "" {
+		log.Printf("未配置 admin_token，管理接口已禁用")
+	} else if config.GlobalConfig.AdminToken == config.GlobalConfig.MyToken {
+		log.Printf("警告: admin_token 与 my_token 相同，持有订阅链接的人可以修改订阅")
+	}
+	if config.GlobalConfig.ShareSecret != "" && config.GlobalConfig.ShareSecret == config.GlobalConfig.MyToken {
+		log.Printf("警告: share_secret 与 my_token 相同，持有订阅链接的人可以自行签发分享链接")
+	}
+	api := r.Group("/api", h.RequireAdmin())
 	{
 		// 订阅管理
 		api.POST("/subscribe", h.AddSubscribe)      // 添加订阅
 		api.DELETE("/subscribe", h.RemoveSubscribe) // 删除订阅
 		api.GET("/subscribe", h.ListSubscribe)      // 列出所有订阅
+
+		// 订阅源管理
+		api.GET("/sources", h.ListSources)
+		api.POST("/sources", h.AddSource)
+		api.GET("/sources/:id", h.GetSource)
+		api.PUT("/sources/:id", h.UpdateSource)
+		api.PATCH("/sources/:id", h.PatchSource)
+		api.DELETE("/sources/:id", h.RemoveSource)
+
+		// 节点解析报告
+		api.GET("/report", h.GetReport)
+
+		// 订阅流量信息
+		api.GET("/userinfo", h.GetUserInfo)
+
+		// 用户管理
+		api.GET("/users", h.ListUsers)
+		api.POST("/users", h.AddUser)
+		api.PUT("/users/:name", h.UpdateUser)
+		api.DELETE("/users/:name", h.RemoveUser)
+
+		// 分享链接
+		api.GET("/share", h.ListShares)
+		api.POST("/share", h.IssueShare)
+		api.DELETE("/share/:id", h.RevokeShare)
 	}
 
-	// 订阅获取路由
+	// 订阅获取路由，可通过 /sub/{target} 或 ?target= 指定输出格式
 	r.GET("/sub", func(c *gin.Context) {
-		h.HandleSubscribe(c.Writer, c.Request)
+		h.HandleSubscribe(c.Writer, c.Request, c.Query("token"), "")
+	})
+	r.GET("/sub/:target", func(c *gin.Context) {
+		h.HandleSubscribe(c.Writer, c.Request, c.Query("token"), c.Param("target"))
+	})
+
+	// 令牌在路径中的订阅链接：/{token}、/{token}/{target} 或 /{token}/{用户名}
+	r.GET("/:token", func(c *gin.Context) {
+		h.HandleSubscribe(c.Writer, c.Request, c.Param("token"), "")
+	})
+	r.GET("/:token/:segment", func(c *gin.Context) {
+		h.HandleSubscribe(c.Writer, c.Request, c.Param("token"), c.Param("segment"))
 	})
 
 	// 启动服务器
@@ -67,11 +119,30 @@ func initConfig() error {
 
 	// 设置默认值
 	viper.SetDefault("my_token", "auto")
+	viper.SetDefault("admin_user", "admin")
 	viper.SetDefault("file_name", "Pages-SUB-Convert")
 	viper.SetDefault("sub_update_time", 6)
 	viper.SetDefault("subconverter", "apiurl.v1.mk")
 	viper.SetDefault("sub_config", "https://raw.githubusercontent.com/cmliu/ACL4SSR/main/Clash/config/ACL4SSR_Online_MultiCountry.ini")
+	viper.SetDefault("singbox_version", "1.11")
+	viper.SetDefault("remote_fallback", false)
+	viper.SetDefault("ruleset_update_time", 24)
+	viper.SetDefault("region_groups.enabled", false)
+	viper.SetDefault("region_groups.type", "url-test")
+	viper.SetDefault("region_groups.url", "https://www.gstatic.com/generate_204")
+	viper.SetDefault("region_groups.interval", 300)
+	viper.SetDefault("region_groups.tolerance", 50)
+	viper.SetDefault("region_groups.min_nodes", 1)
 	viper.SetDefault("subscribe_file", "subscribe.json")
+	viper.SetDefault("users_file", "users.json")
+	viper.SetDefault("share_file", "shares.json")
+	viper.SetDefault("strict_mode", true)
+	viper.SetDefault("dedup_policy", "keep_first")
+	viper.SetDefault("fetch.timeout", 30)
+	viper.SetDefault("fetch.retries", 2)
+	viper.SetDefault("fetch.retry_backoff", 1)
+	viper.SetDefault("fetch.concurrency", 8)
+	viper.SetDefault("fetch.max_body_size", 10)
 
 	// 从环境变量读取配置
 	viper.AutomaticEnv()
@@ -1,19 +1,17 @@
 package config
 
-import (
-	"encoding/json"
-	"io/ioutil"
-	"log"
-	"os"
-	"sync"
-	"time"
-)
-
 type Config struct {
 	// 基本配置
-	MyToken       string `mapstructure:"my_token" json:"my_token"`
-	FileName      string `mapstructure:"file_name" json:"file_name"`
-	SUBUpdateTime int    `mapstructure:"sub_update_time" json:"sub_update_time"`
+	MyToken string `mapstructure:"my_token" json:"my_token"`
+	// AdminToken 管理接口（/api/*）的凭据，与订阅令牌分开，为空时禁用管理接口
+	AdminToken string `mapstructure:"admin_token" json:"admin_token"`
+	// AdminUser 使用 Basic 认证访问管理接口时的用户名
+	AdminUser string `mapstructure:"admin_user" json:"admin_user"`
+	// ShareSecret 分享链接的签名密钥，为空时禁用分享链接
+	ShareSecret string `mapstructure:"share_secret" json:"share_secret"`
+	FileName    string `mapstructure:"file_name" json:"file_name"`
+	// SUBUpdateTime 订阅内容缓存有效期（小时），过期后在下一次请求订阅时才刷新，没有定时刷新
+	SUBUpdateTime int `mapstructure:"sub_update_time" json:"sub_update_time"`
 
 	// Telegram配置
 	TGBotToken    string `mapstructure:"tg_bot_token" json:"tg_bot_token"`
@@ -23,138 +21,127 @@ type Config struct {
 	// 订阅转换配置
 	Subconverter string `mapstructure:"subconverter" json:"subconverter"`
 	SubConfig    string `mapstructure:"sub_config" json:"sub_config"`
+	// RemoteFallback 本地无法加载 sub_config 时改用 Subconverter 远程转换
+	RemoteFallback bool `mapstructure:"remote_fallback" json:"remote_fallback"`
+	// RulesetUpdateTime 远程 ini 配置和规则列表的缓存时间（小时）
+	RulesetUpdateTime int `mapstructure:"ruleset_update_time" json:"ruleset_update_time"`
+
+	// SingBoxVersion 生成sing-box配置的目标版本，如 1.8、1.10、1.11
+	SingBoxVersion string `mapstructure:"singbox_version" json:"singbox_version"`
+	// RegionGroups 按地区自动生成代理组
+	RegionGroups RegionGroupConfig `mapstructure:"region_groups" json:"region_groups"`
 
 	// 节点数据
 	MainData      string   `mapstructure:"main_data" json:"main_data"`
 	SubscribeURLs []string `mapstructure:"subscribe_urls" json:"subscribe_urls"`
 	WarpConfig    string   `mapstructure:"warp_config" json:"warp_config"`
+	// Sources 带名称和过滤规则的静态订阅源
+	Sources []Source `mapstructure:"sources" json:"sources"`
+	// Fetch 获取上游订阅的请求设置
+	Fetch FetchConfig `mapstructure:"fetch" json:"fetch"`
+	// Filter 对所有节点生效的过滤规则
+	Filter FilterConfig `mapstructure:"filter" json:"filter"`
+	// Rename 节点重命名规则
+	Rename RenameConfig `mapstructure:"rename" json:"rename"`
+	// UserInfoSource 返回给客户端的流量信息：为空时汇总所有订阅源，none 不返回，其他值为订阅源名称或URL
+	UserInfoSource string `mapstructure:"userinfo_source" json:"userinfo_source"`
+	// DedupPolicy 重复节点的处理方式：keep_first 保留最先出现的，priority 保留订阅源优先级最高的，off 不去重
+	DedupPolicy string `mapstructure:"dedup_policy" json:"dedup_policy"`
+
+	// StrictMode 丢弃无法解析的节点，关闭时在分享链接输出中原样保留
+	StrictMode bool `mapstructure:"strict_mode" json:"strict_mode"`
 
 	// 动态订阅文件路径
 	SubscribeFile string `mapstructure:"subscribe_file" json:"subscribe_file"`
+	// UsersFile 用户列表文件路径
+	UsersFile string `mapstructure:"users_file" json:"users_file"`
+	// ShareFile 分享链接签发记录和撤销列表的文件路径
+	ShareFile string `mapstructure:"share_file" json:"share_file"`
 }
 
-type DynamicSubscribe struct {
-	URLs []string `json:"urls"`
+// RegionGroupConfig 按节点名称识别地区并为每个地区生成自动测速代理组
+type RegionGroupConfig struct {
+	Enabled bool `mapstructure:"enabled" json:"enabled"`
+	// Type 地区代理组类型：url-test 或 fallback
+	Type      string `mapstructure:"type" json:"type"`
+	URL       string `mapstructure:"url" json:"url"`
+	Interval  int    `mapstructure:"interval" json:"interval"`
+	Tolerance int    `mapstructure:"tolerance" json:"tolerance"`
+	// Regions 只为这些地区代码生成代理组，为空时包含所有识别到的地区
+	Regions []string `mapstructure:"regions" json:"regions"`
+	// MinNodes 地区节点数少于该值时不单独分组
+	MinNodes int `mapstructure:"min_nodes" json:"min_nodes"`
+	// English 使用英文地区名称作为代理组名
+	English bool `mapstructure:"english" json:"english"`
 }
 
-var (
-	GlobalConfig Config
-	dynamicMutex sync.RWMutex
-	dynamicURLs  []string
-)
-
-// LoadDynamicSubscribe 加载动态订阅
-func LoadDynamicSubscribe() error {
-	dynamicMutex.Lock()
-	defer dynamicMutex.Unlock()
-
-	if GlobalConfig.SubscribeFile == "" {
-		GlobalConfig.SubscribeFile = "subscribe.json"
-	}
-
-	// 确保文件存在
-	if _, err := os.Stat(GlobalConfig.SubscribeFile); os.IsNotExist(err) {
-		// 创建默认文件
-		defaultSub := DynamicSubscribe{URLs: []string{}}
-		data, _ := json.MarshalIndent(defaultSub, "", "    ")
-		if err := ioutil.WriteFile(GlobalConfig.SubscribeFile, data, 0644); err != nil {
-			return err
-		}
-	}
-
-	// 读取文件
-	data, err := ioutil.ReadFile(GlobalConfig.SubscribeFile)
-	if err != nil {
-		return err
-	}
-
-	var sub DynamicSubscribe
-	if err := json.Unmarshal(data, &sub); err != nil {
-		return err
-	}
-
-	dynamicURLs = sub.URLs
-	return nil
+// FetchConfig 获取上游订阅的请求设置
+type FetchConfig struct {
+	// Timeout 单次请求的超时时间（秒）
+	Timeout int `mapstructure:"timeout" json:"timeout"`
+	// Retries 请求失败后的重试次数
+	Retries int `mapstructure:"retries" json:"retries"`
+	// RetryBackoff 首次重试前的等待时间（秒），之后每次翻倍
+	RetryBackoff int `mapstructure:"retry_backoff" json:"retry_backoff"`
+	// Concurrency 同时请求的订阅源数量上限
+	Concurrency int `mapstructure:"concurrency" json:"concurrency"`
+	// MaxBodySize 订阅内容的大小上限（MB）
+	MaxBodySize int `mapstructure:"max_body_size" json:"max_body_size"`
+	// UserAgent 请求上游时使用的 UserAgent，部分机场会据此返回不同格式，如 clash.meta
+	UserAgent string `mapstructure:"user_agent" json:"user_agent"`
 }
 
-// GetAllSubscribeURLs 获取所有订阅URL
-func GetAllSubscribeURLs() []string {
-	dynamicMutex.RLock()
-	defer dynamicMutex.RUnlock()
-
-	// 合并静态和动态订阅
-	allURLs := make([]string, 0, len(GlobalConfig.SubscribeURLs)+len(dynamicURLs))
-	allURLs = append(allURLs, GlobalConfig.SubscribeURLs...)
-	allURLs = append(allURLs, dynamicURLs...)
-	return allURLs
+// FilterConfig 节点过滤规则，均为正则表达式，为空时不生效
+type FilterConfig struct {
+	// Include/Exclude 匹配节点名称
+	Include string `mapstructure:"include" json:"include,omitempty"`
+	Exclude string `mapstructure:"exclude" json:"exclude,omitempty"`
+	// IncludeProtocol/ExcludeProtocol 匹配协议类型，如 vmess、ss、hysteria2
+	IncludeProtocol string `mapstructure:"include_protocol" json:"include_protocol,omitempty"`
+	ExcludeProtocol string `mapstructure:"exclude_protocol" json:"exclude_protocol,omitempty"`
+	// IncludeServer/ExcludeServer 匹配服务器地址
+	IncludeServer string `mapstructure:"include_server" json:"include_server,omitempty"`
+	ExcludeServer string `mapstructure:"exclude_server" json:"exclude_server,omitempty"`
 }
 
-// AddSubscribeURL 添加新的订阅URL
-func AddSubscribeURL(url string) error {
-	dynamicMutex.Lock()
-	defer dynamicMutex.Unlock()
-
-	// 检查URL是否已存在
-	for _, existingURL := range dynamicURLs {
-		if existingURL == url {
-			return nil
-		}
-	}
-
-	// 添加新URL
-	dynamicURLs = append(dynamicURLs, url)
-
-	// 保存到文件
-	sub := DynamicSubscribe{URLs: dynamicURLs}
-	data, err := json.MarshalIndent(sub, "", "    ")
-	if err != nil {
-		return err
-	}
-
-	return ioutil.WriteFile(GlobalConfig.SubscribeFile, data, 0644)
+// RenameRule 正则替换规则，Replace 中可使用 $1 等引用分组
+type RenameRule struct {
+	Pattern string `mapstructure:"pattern" json:"pattern"`
+	Replace string `mapstructure:"replace" json:"replace"`
 }
 
-// RemoveSubscribeURL 移除订阅URL
-func RemoveSubscribeURL(url string) error {
-	dynamicMutex.Lock()
-	defer dynamicMutex.Unlock()
-
-	// 查找并移除URL
-	newURLs := make([]string, 0, len(dynamicURLs))
-	for _, existingURL := range dynamicURLs {
-		if existingURL != url {
-			newURLs = append(newURLs, existingURL)
-		}
-	}
-
-	dynamicURLs = newURLs
-
-	// 保存到文件
-	sub := DynamicSubscribe{URLs: dynamicURLs}
-	data, err := json.MarshalIndent(sub, "", "    ")
-	if err != nil {
-		return err
-	}
-
-	return ioutil.WriteFile(GlobalConfig.SubscribeFile, data, 0644)
+// RenameConfig 节点重命名，依次执行正则替换、命名模板、来源前后缀、添加地区旗帜
+type RenameConfig struct {
+	Rules []RenameRule `mapstructure:"rules" json:"rules"`
+	// Template 命名模板，如 "{region}-{index:02}"，为空时保留原名称
+	Template string `mapstructure:"template" json:"template"`
+	// SourcePrefix/SourceSuffix 在名称前后添加订阅源名称，模板包含 {source} 时忽略
+	SourcePrefix bool `mapstructure:"source_prefix" json:"source_prefix"`
+	SourceSuffix bool `mapstructure:"source_suffix" json:"source_suffix"`
+	// Emoji 在名称前添加识别到的地区旗帜
+	Emoji bool `mapstructure:"emoji" json:"emoji"`
 }
 
-// WatchSubscribeFile 监视订阅文件变化
-func WatchSubscribeFile() error {
-	// 启动文件监视
-	go func() {
-		for {
-			// 每30秒检查一次文件变化
-			time.Sleep(30 * time.Second)
-			if err := LoadDynamicSubscribe(); err != nil {
-				log.Printf("重新加载订阅文件失败: %v", err)
-			}
-		}
-	}()
-
-	return nil
+// Source 订阅源
+type Source struct {
+	// Name 订阅源名称，用于日志和报告，为空时使用URL
+	Name   string       `mapstructure:"name" json:"name"`
+	URL    string       `mapstructure:"url" json:"url"`
+	Filter FilterConfig `mapstructure:"filter" json:"filter"`
+	// Priority 去重策略为 priority 时，重复节点保留优先级最高的订阅源中的节点
+	Priority int `mapstructure:"priority" json:"priority"`
+	// Timeout/UserAgent 覆盖 fetch 中的全局设置，为空时使用全局设置
+	Timeout   int    `mapstructure:"timeout" json:"timeout,omitempty"`
+	UserAgent string `mapstructure:"user_agent" json:"user_agent,omitempty"`
+	// Headers 请求上游时附加的请求头，其中的 User-Agent 优先于 user_agent
+	Headers map[string]string `mapstructure:"headers" json:"headers,omitempty"`
+	// RefreshInterval 缓存有效期（秒），覆盖 sub_update_time，为0时使用全局设置。
+	// 只决定缓存何时过期，过期后由下一次订阅请求触发刷新，不会按间隔定时请求上游
+	RefreshInterval int `mapstructure:"refresh_interval" json:"refresh_interval,omitempty"`
 }
 
+var GlobalConfig Config
+
 func Init() error {
 	// TODO: 从环境变量或配置文件加载配置
 	return nil
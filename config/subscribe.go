@@ -0,0 +1,338 @@
+package config
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrSourceNotFound 订阅源不存在
+	ErrSourceNotFound = errors.New("订阅源不存在")
+	// ErrSourceExists 订阅源名称或URL已被使用
+	ErrSourceExists = errors.New("订阅源名称或URL已存在")
+)
+
+// ManagedSource 通过管理接口维护的订阅源，保存在 subscribe_file 中
+type ManagedSource struct {
+	ID string `json:"id"`
+	Source
+	// Enabled 停用后不参与合并，但保留配置
+	Enabled bool `json:"enabled"`
+	// Tags 用于分类和筛选的标签
+	Tags []string `json:"tags,omitempty"`
+}
+
+// DynamicSubscribe subscribe_file 的内容，URLs 为旧版本只保存地址的格式，加载时转换为 Sources
+type DynamicSubscribe struct {
+	Sources []ManagedSource `json:"sources"`
+	URLs    []string        `json:"urls,omitempty"`
+}
+
+var (
+	dynamicMutex   sync.RWMutex
+	dynamicSources []ManagedSource
+)
+
+// LoadDynamicSubscribe 加载动态订阅，文件不存在时视为没有动态订阅。旧格式和缺少ID的订阅源只在内存中转换，
+// 下次修改时以新格式写回。文件无法读取或解析时返回错误且保留已加载的内容，启动时调用方不能继续运行，
+// 否则之后的保存会覆盖文件中的订阅源
+func LoadDynamicSubscribe() error {
+	dynamicMutex.Lock()
+	defer dynamicMutex.Unlock()
+
+	if GlobalConfig.SubscribeFile == "" {
+		GlobalConfig.SubscribeFile = "subscribe.json"
+	}
+
+	data, err := os.ReadFile(GlobalConfig.SubscribeFile)
+	if os.IsNotExist(err) {
+		dynamicSources = nil
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var sub DynamicSubscribe
+	if err := json.Unmarshal(data, &sub); err != nil {
+		return fmt.Errorf("解析 %s 失败: %w", GlobalConfig.SubscribeFile, err)
+	}
+
+	sources, err := migrateDynamicSubscribe(sub, dynamicSources)
+	if err != nil {
+		return err
+	}
+	dynamicSources = sources
+	return nil
+}
+
+// migrateDynamicSubscribe 将旧格式的地址列表转换为订阅源，并为缺少ID的订阅源生成ID。
+// 文件定期重新加载，地址相同的订阅源沿用 previous 中的ID，保证ID不变
+func migrateDynamicSubscribe(sub DynamicSubscribe, previous []ManagedSource) ([]ManagedSource, error) {
+	sources := append([]ManagedSource{}, sub.Sources...)
+	for _, url := range sub.URLs {
+		if findSource(sources, "", url) >= 0 {
+			continue
+		}
+		sources = append(sources, ManagedSource{Source: Source{Name: url, URL: url}, Enabled: true})
+	}
+
+	for i := range sources {
+		if sources[i].ID != "" {
+			continue
+		}
+		if j := findSource(previous, "", sources[i].URL); j >= 0 && !sourceIDUsed(sources, previous[j].ID) {
+			sources[i].ID = previous[j].ID
+			continue
+		}
+		id, err := newSourceID(sources)
+		if err != nil {
+			return nil, err
+		}
+		sources[i].ID = id
+	}
+	return sources, nil
+}
+
+// GetAllSubscribeURLs 获取所有订阅URL
+func GetAllSubscribeURLs() []string {
+	dynamicMutex.RLock()
+	defer dynamicMutex.RUnlock()
+
+	// 合并静态和动态订阅
+	allURLs := make([]string, 0, len(GlobalConfig.SubscribeURLs)+len(dynamicSources))
+	allURLs = append(allURLs, GlobalConfig.SubscribeURLs...)
+	for _, s := range dynamicSources {
+		allURLs = append(allURLs, s.URL)
+	}
+	return allURLs
+}
+
+// GetAllSources 获取所有启用的订阅源，依次为 sources、subscribe_urls 和动态订阅
+func GetAllSources() []Source {
+	dynamicMutex.RLock()
+	defer dynamicMutex.RUnlock()
+
+	sources := make([]Source, 0, len(GlobalConfig.Sources)+len(GlobalConfig.SubscribeURLs)+len(dynamicSources))
+	for _, s := range GlobalConfig.Sources {
+		if s.Name == "" {
+			s.Name = s.URL
+		}
+		sources = append(sources, s)
+	}
+	for _, url := range GlobalConfig.SubscribeURLs {
+		sources = append(sources, Source{Name: url, URL: url})
+	}
+	for _, s := range dynamicSources {
+		if s.Enabled {
+			sources = append(sources, s.Source)
+		}
+	}
+	return sources
+}
+
+// GetManagedSources 返回所有动态订阅源，包括已停用的
+func GetManagedSources() []ManagedSource {
+	dynamicMutex.RLock()
+	defer dynamicMutex.RUnlock()
+
+	return append([]ManagedSource{}, dynamicSources...)
+}
+
+// GetManagedSource 按ID查找动态订阅源
+func GetManagedSource(id string) (ManagedSource, error) {
+	dynamicMutex.RLock()
+	defer dynamicMutex.RUnlock()
+
+	for _, s := range dynamicSources {
+		if s.ID == id {
+			return s, nil
+		}
+	}
+	return ManagedSource{}, ErrSourceNotFound
+}
+
+// AddManagedSource 添加动态订阅源并生成ID，名称为空时使用URL；
+// 名称或URL与配置文件或已有的动态订阅源相同时返回 ErrSourceExists
+func AddManagedSource(s ManagedSource) (ManagedSource, error) {
+	dynamicMutex.Lock()
+	defer dynamicMutex.Unlock()
+
+	if s.Name == "" {
+		s.Name = s.URL
+	}
+	if staticSourceExists(s.Name, s.URL) || findSource(dynamicSources, s.Name, s.URL) >= 0 {
+		return ManagedSource{}, ErrSourceExists
+	}
+
+	id, err := newSourceID(dynamicSources)
+	if err != nil {
+		return ManagedSource{}, err
+	}
+	s.ID = id
+
+	next := append(append([]ManagedSource{}, dynamicSources...), s)
+	if err := saveDynamicSubscribe(next); err != nil {
+		return ManagedSource{}, err
+	}
+	dynamicSources = next
+	return s, nil
+}
+
+// UpdateManagedSource 替换指定ID的动态订阅源，名称为空时使用URL
+func UpdateManagedSource(id string, s ManagedSource) (ManagedSource, error) {
+	dynamicMutex.Lock()
+	defer dynamicMutex.Unlock()
+
+	if s.Name == "" {
+		s.Name = s.URL
+	}
+	s.ID = id
+
+	index := -1
+	for i, existing := range dynamicSources {
+		if existing.ID == id {
+			index = i
+			continue
+		}
+		if existing.Name == s.Name || existing.URL == s.URL {
+			return ManagedSource{}, ErrSourceExists
+		}
+	}
+	if index < 0 {
+		return ManagedSource{}, ErrSourceNotFound
+	}
+	if staticSourceExists(s.Name, s.URL) {
+		return ManagedSource{}, ErrSourceExists
+	}
+
+	next := append([]ManagedSource{}, dynamicSources...)
+	next[index] = s
+	if err := saveDynamicSubscribe(next); err != nil {
+		return ManagedSource{}, err
+	}
+	dynamicSources = next
+	return s, nil
+}
+
+// RemoveManagedSource 删除指定ID的动态订阅源
+func RemoveManagedSource(id string) error {
+	dynamicMutex.Lock()
+	defer dynamicMutex.Unlock()
+
+	for i, s := range dynamicSources {
+		if s.ID == id {
+			return removeDynamicSource(i)
+		}
+	}
+	return ErrSourceNotFound
+}
+
+// AddSubscribeURL 添加新的订阅URL，已存在时返回 ErrSourceExists
+func AddSubscribeURL(url string) error {
+	_, err := AddManagedSource(ManagedSource{Source: Source{Name: url, URL: url}, Enabled: true})
+	return err
+}
+
+// RemoveSubscribeURL 按地址移除动态订阅源，不存在时返回 ErrSourceNotFound
+func RemoveSubscribeURL(url string) error {
+	dynamicMutex.Lock()
+	defer dynamicMutex.Unlock()
+
+	index := findSource(dynamicSources, "", url)
+	if index < 0 {
+		return ErrSourceNotFound
+	}
+	return removeDynamicSource(index)
+}
+
+// removeDynamicSource 删除第 index 个动态订阅源并保存，调用方需持有 dynamicMutex
+func removeDynamicSource(index int) error {
+	next := append(append([]ManagedSource{}, dynamicSources[:index]...), dynamicSources[index+1:]...)
+	if err := saveDynamicSubscribe(next); err != nil {
+		return err
+	}
+	dynamicSources = next
+	return nil
+}
+
+// findSource 返回名称或URL相同的订阅源的位置，name 为空时只比较URL
+func findSource(sources []ManagedSource, name, url string) int {
+	for i, s := range sources {
+		if (name != "" && s.Name == name) || s.URL == url {
+			return i
+		}
+	}
+	return -1
+}
+
+// staticSourceExists 判断配置文件中是否已有相同名称或URL的订阅源
+func staticSourceExists(name, url string) bool {
+	for _, s := range GlobalConfig.Sources {
+		if s.URL == url || (s.Name != "" && s.Name == name) {
+			return true
+		}
+	}
+	for _, u := range GlobalConfig.SubscribeURLs {
+		if u == url || u == name {
+			return true
+		}
+	}
+	return false
+}
+
+// newSourceID 生成不与已有订阅源重复的ID
+func newSourceID(sources []ManagedSource) (string, error) {
+	buf := make([]byte, 6)
+	for {
+		if _, err := rand.Read(buf); err != nil {
+			return "", err
+		}
+		if id := hex.EncodeToString(buf); !sourceIDUsed(sources, id) {
+			return id, nil
+		}
+	}
+}
+
+// sourceIDUsed 判断ID是否已被使用
+func sourceIDUsed(sources []ManagedSource, id string) bool {
+	for _, s := range sources {
+		if s.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// saveDynamicSubscribe 写入订阅文件，调用方需持有 dynamicMutex
+func saveDynamicSubscribe(sources []ManagedSource) error {
+	data, err := json.MarshalIndent(DynamicSubscribe{Sources: sources}, "", "    ")
+	if err != nil {
+		return err
+	}
+	// 请求头中可能包含上游凭据，仅允许当前用户读取
+	return os.WriteFile(GlobalConfig.SubscribeFile, data, 0600)
+}
+
+// WatchSubscribeFile 监视订阅文件变化
+func WatchSubscribeFile() error {
+	// 启动文件监视
+	go func() {
+		for {
+			// 每30秒检查一次文件变化
+			time.Sleep(30 * time.Second)
+			if err := LoadDynamicSubscribe(); err != nil {
+				log.Printf("重新加载订阅文件失败: %v", err)
+			}
+		}
+	}()
+
+	return nil
+}
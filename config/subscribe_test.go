@@ -0,0 +1,170 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"testing"
+)
+
+// useSubscribeFile 使用临时的订阅文件，测试结束后恢复全局状态
+func useSubscribeFile(t *testing.T, content string) string {
+	t.Helper()
+	file := filepath.Join(t.TempDir(), "subscribe.json")
+	if content != "" {
+		if err := os.WriteFile(file, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	saved := GlobalConfig
+	GlobalConfig = Config{SubscribeFile: file}
+	dynamicSources = nil
+	t.Cleanup(func() {
+		GlobalConfig = saved
+		dynamicSources = nil
+	})
+	return file
+}
+
+var sourceIDPattern = regexp.MustCompile(`^[0-9a-f]{12}$`)
+
+func TestMigrateDynamicSubscribe(t *testing.T) {
+	sub := DynamicSubscribe{
+		Sources: []ManagedSource{
+			{ID: "aaaaaaaaaaaa", Source: Source{Name: "A", URL: "https://a.example.com"}},
+			{Source: Source{Name: "B", URL: "https://b.example.com"}, Enabled: true},
+		},
+		URLs: []string{"https://a.example.com", "https://c.example.com", "https://d.example.com"},
+	}
+	previous := []ManagedSource{{ID: "cccccccccccc", Source: Source{URL: "https://c.example.com"}}}
+
+	sources, err := migrateDynamicSubscribe(sub, previous)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 已有的地址不重复添加，旧格式的地址转换为启用的订阅源
+	var urls []string
+	for _, s := range sources {
+		urls = append(urls, s.URL)
+	}
+	wantURLs := []string{"https://a.example.com", "https://b.example.com", "https://c.example.com", "https://d.example.com"}
+	if !reflect.DeepEqual(urls, wantURLs) {
+		t.Fatalf("得到 %q，期望 %q", urls, wantURLs)
+	}
+	if d := sources[3]; d.Name != d.URL || !d.Enabled {
+		t.Errorf("旧格式地址转换为 %+v，期望名称为地址且启用", d)
+	}
+
+	// 已有ID保持不变，重新加载时沿用之前的ID，其余生成不重复的ID
+	if sources[0].ID != "aaaaaaaaaaaa" || sources[2].ID != "cccccccccccc" {
+		t.Errorf("ID 为 %q 和 %q，期望保持不变", sources[0].ID, sources[2].ID)
+	}
+	seen := make(map[string]bool)
+	for _, s := range sources {
+		if !sourceIDPattern.MatchString(s.ID) || seen[s.ID] {
+			t.Errorf("ID %q 无效或重复", s.ID)
+		}
+		seen[s.ID] = true
+	}
+}
+
+func TestLoadDynamicSubscribeDoesNotWrite(t *testing.T) {
+	// 文件不存在时不创建
+	file := useSubscribeFile(t, "")
+	if err := LoadDynamicSubscribe(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(file); !os.IsNotExist(err) {
+		t.Errorf("加载时创建了订阅文件: %v", err)
+	}
+
+	// 旧格式只在内存中转换，重新加载后ID不变
+	legacy := `{"urls":["https://a.example.com"]}`
+	file = useSubscribeFile(t, legacy)
+	if err := LoadDynamicSubscribe(); err != nil {
+		t.Fatal(err)
+	}
+	first := GetManagedSources()
+	if err := LoadDynamicSubscribe(); err != nil {
+		t.Fatal(err)
+	}
+	if got := GetManagedSources(); len(got) != 1 || got[0].ID != first[0].ID {
+		t.Errorf("重新加载得到 %+v，期望ID保持为 %q", got, first[0].ID)
+	}
+	if data, _ := os.ReadFile(file); string(data) != legacy {
+		t.Errorf("加载时改写了订阅文件: %s", data)
+	}
+
+	// 文件损坏时返回错误，保留已加载的订阅源
+	if err := os.WriteFile(file, []byte("{bad"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := LoadDynamicSubscribe(); err == nil {
+		t.Error("期望返回错误")
+	}
+	if got := GetManagedSources(); len(got) != 1 {
+		t.Errorf("加载失败后订阅源为 %+v，期望保留", got)
+	}
+	if data, _ := os.ReadFile(file); string(data) != "{bad" {
+		t.Errorf("加载失败时改写了订阅文件: %s", data)
+	}
+}
+
+func TestManagedSourceCRUD(t *testing.T) {
+	useSubscribeFile(t, "")
+	GlobalConfig.SubscribeURLs = []string{"https://static.example.com"}
+
+	a, err := AddManagedSource(ManagedSource{Source: Source{URL: "https://a.example.com"}, Enabled: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Name != a.URL || !sourceIDPattern.MatchString(a.ID) {
+		t.Errorf("添加得到 %+v，期望名称为地址并生成ID", a)
+	}
+	b, err := AddManagedSource(ManagedSource{Source: Source{Name: "B", URL: "https://b.example.com"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exists := []ManagedSource{
+		{Source: Source{Name: "C", URL: "https://a.example.com"}},
+		{Source: Source{Name: "B", URL: "https://c.example.com"}},
+		{Source: Source{Name: "C", URL: "https://static.example.com"}},
+	}
+	for _, s := range exists {
+		if _, err := AddManagedSource(s); !errors.Is(err, ErrSourceExists) {
+			t.Errorf("添加 %+v 得到 %v，期望 %v", s.Source, err, ErrSourceExists)
+		}
+	}
+	if _, err := UpdateManagedSource(b.ID, ManagedSource{Source: Source{Name: "B", URL: "https://a.example.com"}}); !errors.Is(err, ErrSourceExists) {
+		t.Errorf("修改为已有地址得到 %v，期望 %v", err, ErrSourceExists)
+	}
+	if _, err := UpdateManagedSource("missing", ManagedSource{Source: Source{URL: "https://d.example.com"}}); !errors.Is(err, ErrSourceNotFound) {
+		t.Errorf("修改不存在的订阅源得到 %v，期望 %v", err, ErrSourceNotFound)
+	}
+
+	// 修改时可以保留自己的名称和地址，保存后重新加载内容不变
+	b.Enabled = true
+	if _, err := UpdateManagedSource(b.ID, b); err != nil {
+		t.Fatal(err)
+	}
+	if err := LoadDynamicSubscribe(); err != nil {
+		t.Fatal(err)
+	}
+	if got := GetManagedSources(); !reflect.DeepEqual(got, []ManagedSource{a, b}) {
+		t.Errorf("重新加载得到 %+v，期望 %+v", got, []ManagedSource{a, b})
+	}
+
+	if err := RemoveManagedSource(a.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := RemoveManagedSource(a.ID); !errors.Is(err, ErrSourceNotFound) {
+		t.Errorf("删除不存在的订阅源得到 %v，期望 %v", err, ErrSourceNotFound)
+	}
+	if _, err := GetManagedSource(a.ID); !errors.Is(err, ErrSourceNotFound) {
+		t.Errorf("查看已删除的订阅源得到 %v，期望 %v", err, ErrSourceNotFound)
+	}
+}
@@ -0,0 +1,216 @@
+package config
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+)
+
+var (
+	// ErrUserNotFound 用户不存在
+	ErrUserNotFound = errors.New("用户不存在")
+	// ErrUserExists 用户名或令牌已被使用
+	ErrUserExists = errors.New("用户名或令牌已存在")
+)
+
+// User 使用独立令牌访问订阅的用户，可以限制订阅源、过滤规则和默认输出格式
+type User struct {
+	Name  string `json:"name"`
+	Token string `json:"token"`
+	// Disabled 停用后该令牌无法获取订阅
+	Disabled bool `json:"disabled"`
+	// Sources 可使用的订阅源名称或URL，"main" 表示 main_data 中的节点，为空时使用全部订阅源
+	Sources []string `json:"sources,omitempty"`
+	// Filter 只对该用户生效的过滤规则
+	Filter FilterConfig `json:"filter"`
+	// Target 默认输出格式，请求中未指定时使用，为空时按 UserAgent 识别
+	Target string `json:"target,omitempty"`
+}
+
+type usersFile struct {
+	Users []User `json:"users"`
+}
+
+var (
+	usersMutex sync.RWMutex
+	users      []User
+)
+
+// LoadUsers 加载用户列表，文件不存在时视为没有用户。文件无法读取或解析时返回错误，
+// 调用方不能继续运行，否则之后的保存会覆盖文件中的用户
+func LoadUsers() error {
+	usersMutex.Lock()
+	defer usersMutex.Unlock()
+
+	if GlobalConfig.UsersFile == "" {
+		GlobalConfig.UsersFile = "users.json"
+	}
+
+	data, err := os.ReadFile(GlobalConfig.UsersFile)
+	if os.IsNotExist(err) {
+		users = nil
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var f usersFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return fmt.Errorf("解析 %s 失败: %w", GlobalConfig.UsersFile, err)
+	}
+	users = f.Users
+	return nil
+}
+
+// FindUserByToken 按令牌查找用户
+func FindUserByToken(token string) (User, bool) {
+	usersMutex.RLock()
+	defer usersMutex.RUnlock()
+
+	if token == "" {
+		return User{}, false
+	}
+	// 固定时间比较，避免通过响应时间猜测令牌
+	for _, u := range users {
+		if subtle.ConstantTimeCompare([]byte(u.Token), []byte(token)) == 1 {
+			return u, true
+		}
+	}
+	return User{}, false
+}
+
+// FindUserByName 按名称查找用户
+func FindUserByName(name string) (User, bool) {
+	usersMutex.RLock()
+	defer usersMutex.RUnlock()
+
+	for _, u := range users {
+		if u.Name == name {
+			return u, true
+		}
+	}
+	return User{}, false
+}
+
+// GetUsers 返回所有用户
+func GetUsers() []User {
+	usersMutex.RLock()
+	defer usersMutex.RUnlock()
+
+	return append([]User{}, users...)
+}
+
+// AddUser 添加用户，令牌为空时自动生成
+func AddUser(u User) (User, error) {
+	usersMutex.Lock()
+	defer usersMutex.Unlock()
+
+	if u.Token == "" {
+		token, err := GenerateToken()
+		if err != nil {
+			return User{}, err
+		}
+		u.Token = token
+	}
+	if reservedToken(u.Token) {
+		return User{}, ErrUserExists
+	}
+	for _, existing := range users {
+		if existing.Name == u.Name || existing.Token == u.Token {
+			return User{}, ErrUserExists
+		}
+	}
+
+	next := append(append([]User{}, users...), u)
+	if err := saveUsers(next); err != nil {
+		return User{}, err
+	}
+	users = next
+	return u, nil
+}
+
+// UpdateUser 替换指定名称的用户，新令牌为空时保留原令牌
+func UpdateUser(name string, u User) (User, error) {
+	usersMutex.Lock()
+	defer usersMutex.Unlock()
+
+	index := -1
+	for i, existing := range users {
+		if existing.Name == name {
+			index = i
+			continue
+		}
+		if existing.Name == u.Name || (u.Token != "" && existing.Token == u.Token) {
+			return User{}, ErrUserExists
+		}
+	}
+	if index < 0 {
+		return User{}, ErrUserNotFound
+	}
+	if u.Token == "" {
+		u.Token = users[index].Token
+	}
+	if reservedToken(u.Token) {
+		return User{}, ErrUserExists
+	}
+
+	next := append([]User{}, users...)
+	next[index] = u
+	if err := saveUsers(next); err != nil {
+		return User{}, err
+	}
+	users = next
+	return u, nil
+}
+
+// RemoveUser 删除指定名称的用户
+func RemoveUser(name string) error {
+	usersMutex.Lock()
+	defer usersMutex.Unlock()
+
+	next := make([]User, 0, len(users))
+	for _, u := range users {
+		if u.Name != name {
+			next = append(next, u)
+		}
+	}
+	if len(next) == len(users) {
+		return ErrUserNotFound
+	}
+
+	if err := saveUsers(next); err != nil {
+		return err
+	}
+	users = next
+	return nil
+}
+
+// reservedToken 判断令牌是否与 my_token 或管理令牌相同
+func reservedToken(token string) bool {
+	return token == GlobalConfig.MyToken || token == GlobalConfig.AdminToken
+}
+
+// GenerateToken 生成随机令牌
+func GenerateToken() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// saveUsers 写入用户文件，调用方需持有 usersMutex
+func saveUsers(list []User) error {
+	data, err := json.MarshalIndent(usersFile{Users: list}, "", "    ")
+	if err != nil {
+		return err
+	}
+	// 文件中包含令牌，仅允许当前用户读取
+	return os.WriteFile(GlobalConfig.UsersFile, data, 0600)
+}
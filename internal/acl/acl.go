@@ -0,0 +1,323 @@
+package acl
+
+import (
+	"bufio"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Rule 单条分流规则，如 DOMAIN-SUFFIX,google.com,no-resolve
+type Rule struct {
+	Type    string
+	Value   string
+	Options []string
+}
+
+// Ruleset ruleset= 配置项，将一组规则指向某个代理组
+type Ruleset struct {
+	Group string
+	// Source 规则列表的地址，内联规则（[]GEOIP,CN）时为空
+	Source string
+	// Format 规则列表格式，如 clash-domain、clash-ipcidr、clash-classic、quanx，为空时自动识别
+	Format string
+	// Interval 规则列表的更新间隔（秒），为0时使用默认值
+	Interval int
+	Rules    []Rule
+}
+
+// rulesetFormats ruleset 来源可以携带的格式前缀
+var rulesetFormats = []string{"clash-domain", "clash-ipcidr", "clash-classic", "surge", "quanx"}
+
+// ruleAliases 其他客户端规则类型对应的Clash规则类型
+var ruleAliases = map[string]string{
+	"FINAL":        "MATCH",
+	"HOST":         "DOMAIN",
+	"HOST-SUFFIX":  "DOMAIN-SUFFIX",
+	"HOST-KEYWORD": "DOMAIN-KEYWORD",
+	"HOST-REGEX":   "DOMAIN-REGEX",
+	"IP6-CIDR":     "IP-CIDR6",
+	"DEST-PORT":    "DST-PORT",
+	"SRC-IP":       "SRC-IP-CIDR",
+}
+
+// ProxyGroup custom_proxy_group= 配置项
+type ProxyGroup struct {
+	Name string
+	Type string
+	// Members 中 "[]名称" 表示直接引用，其余为匹配节点名称的正则
+	Members   []string
+	URL       string
+	Interval  int
+	Tolerance int
+}
+
+// Template 解析后的 ACL4SSR 风格 ini 配置
+type Template struct {
+	Rulesets            []Ruleset
+	ProxyGroups         []ProxyGroup
+	EnableRuleGenerator bool
+}
+
+// Parse 解析 ini 内容，未识别的配置项会被忽略
+func Parse(content string) *Template {
+	t := &Template{EnableRuleGenerator: true}
+
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, ";") || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "[") {
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+
+		switch strings.TrimSpace(key) {
+		case "ruleset":
+			if rs, ok := parseRuleset(value); ok {
+				t.Rulesets = append(t.Rulesets, rs)
+			}
+		case "custom_proxy_group":
+			if g, ok := parseProxyGroup(value); ok {
+				t.ProxyGroups = append(t.ProxyGroups, g)
+			}
+		case "enable_rule_generator":
+			t.EnableRuleGenerator = value != "false"
+		}
+	}
+	return t
+}
+
+// parseRuleset 解析 "组名,[格式:]规则来源[,更新间隔]" 或 "组名,[]内联规则"
+func parseRuleset(value string) (Ruleset, bool) {
+	group, source, ok := strings.Cut(value, ",")
+	if !ok || group == "" {
+		return Ruleset{}, false
+	}
+
+	rs := Ruleset{Group: strings.TrimSpace(group)}
+	source = strings.TrimSpace(source)
+	if strings.HasPrefix(source, "[]") {
+		rule, ok := ParseRule(strings.TrimPrefix(source, "[]"))
+		if !ok {
+			return Ruleset{}, false
+		}
+		rs.Rules = []Rule{rule}
+		return rs, true
+	}
+
+	if i := strings.LastIndex(source, ","); i >= 0 {
+		if interval, err := strconv.Atoi(strings.TrimSpace(source[i+1:])); err == nil {
+			rs.Interval = interval
+			source = strings.TrimSpace(source[:i])
+		}
+	}
+	for _, format := range rulesetFormats {
+		if strings.HasPrefix(source, format+":") {
+			rs.Format = format
+			source = strings.TrimPrefix(source, format+":")
+			break
+		}
+	}
+
+	rs.Source = source
+	return rs, rs.Source != ""
+}
+
+// parseProxyGroup 解析 "名称`类型`成员...`测速地址`间隔,,容差"
+func parseProxyGroup(value string) (ProxyGroup, bool) {
+	fields := strings.Split(value, "`")
+	if len(fields) < 3 {
+		return ProxyGroup{}, false
+	}
+
+	g := ProxyGroup{Name: fields[0], Type: fields[1]}
+	members := fields[2:]
+	if g.Type != "select" {
+		// 自动测速类代理组的最后两项为测速地址和间隔参数
+		if len(members) < 3 {
+			return ProxyGroup{}, false
+		}
+		params := strings.Split(members[len(members)-1], ",")
+		g.URL = members[len(members)-2]
+		g.Interval, _ = strconv.Atoi(params[0])
+		if len(params) >= 3 {
+			g.Tolerance, _ = strconv.Atoi(params[2])
+		}
+		members = members[:len(members)-2]
+	}
+
+	for _, m := range members {
+		if m = strings.TrimSpace(m); m == "" {
+			continue
+		}
+		if !strings.HasPrefix(m, "[]") {
+			if _, err := compileMember(m); err != nil {
+				log.Printf("代理组 %s 的成员规则 %q 无效: %v", g.Name, m, err)
+				continue
+			}
+		}
+		g.Members = append(g.Members, m)
+	}
+	return g, len(g.Members) > 0
+}
+
+// ParseRule 解析一行规则，规则类型统一为Clash的名称（FINAL 为 MATCH，HOST-SUFFIX 为 DOMAIN-SUFFIX 等）；
+// 选项只保留 no-resolve，QuanX 规则末尾的策略名会被忽略
+func ParseRule(line string) (Rule, bool) {
+	parts := strings.Split(strings.TrimSpace(line), ",")
+	for i := range parts {
+		parts[i] = strings.TrimSpace(parts[i])
+	}
+
+	rule := Rule{Type: strings.ToUpper(parts[0])}
+	if alias, ok := ruleAliases[rule.Type]; ok {
+		rule.Type = alias
+	}
+	switch rule.Type {
+	case "":
+		return Rule{}, false
+	case "MATCH":
+		return rule, true
+	}
+	if len(parts) < 2 || parts[1] == "" {
+		return Rule{}, false
+	}
+	rule.Value = parts[1]
+	for _, o := range parts[2:] {
+		if strings.EqualFold(o, "no-resolve") {
+			rule.Options = append(rule.Options, "no-resolve")
+		}
+	}
+	return rule, true
+}
+
+// ParseRuleList 解析规则列表文件，跳过注释和空行；
+// 格式为空时根据是否包含 payload: 区分Clash规则集和Surge/QuanX列表
+func ParseRuleList(content, format string) []Rule {
+	if format == "" || format == "surge" || format == "quanx" {
+		if strings.Contains(content, "payload:") {
+			format = "clash-classic"
+		}
+	}
+
+	var rules []Rule
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") || strings.HasPrefix(line, "//") {
+			continue
+		}
+
+		if strings.HasPrefix(format, "clash-") {
+			// Clash 规则集为YAML列表：payload: 下的 "- 条目"
+			if !strings.HasPrefix(line, "-") {
+				continue
+			}
+			line = strings.Trim(strings.TrimSpace(strings.TrimPrefix(line, "-")), `"'`)
+			switch format {
+			case "clash-domain":
+				line = domainRule(line)
+			case "clash-ipcidr":
+				line = ipcidrRule(line)
+			}
+			if line == "" {
+				continue
+			}
+		}
+
+		if rule, ok := ParseRule(line); ok && rule.Type != "MATCH" {
+			rules = append(rules, rule)
+		}
+	}
+	return rules
+}
+
+// domainRule 将Clash domain 规则集条目转换为规则行
+func domainRule(entry string) string {
+	switch {
+	case strings.HasPrefix(entry, "+."):
+		return "DOMAIN-SUFFIX," + strings.TrimPrefix(entry, "+.")
+	case strings.HasPrefix(entry, "."):
+		return "DOMAIN-SUFFIX," + strings.TrimPrefix(entry, ".")
+	case strings.Contains(entry, "*"):
+		// 通配符无法用普通域名规则表示
+		return ""
+	}
+	return "DOMAIN," + entry
+}
+
+// ipcidrRule 将Clash ipcidr 规则集条目转换为规则行
+func ipcidrRule(entry string) string {
+	if strings.Contains(entry, ":") {
+		return "IP-CIDR6," + entry + ",no-resolve"
+	}
+	return "IP-CIDR," + entry + ",no-resolve"
+}
+
+// Resolve 根据成员定义展开代理组包含的节点和代理组，没有匹配时返回 DIRECT
+func (g ProxyGroup) Resolve(nodeNames []string) []string {
+	var result []string
+	seen := make(map[string]struct{})
+	add := func(name string) {
+		if _, ok := seen[name]; !ok {
+			seen[name] = struct{}{}
+			result = append(result, name)
+		}
+	}
+
+	for _, m := range g.Members {
+		if strings.HasPrefix(m, "[]") {
+			add(strings.TrimPrefix(m, "[]"))
+			continue
+		}
+
+		matcher, err := compileMember(m)
+		if err != nil {
+			continue
+		}
+		for _, name := range nodeNames {
+			if matcher.match(name) {
+				add(name)
+			}
+		}
+	}
+
+	if len(result) == 0 {
+		result = []string{"DIRECT"}
+	}
+	return result
+}
+
+// memberMatcher 代理组成员的节点名称匹配规则
+type memberMatcher struct {
+	re     *regexp.Regexp
+	negate bool
+}
+
+func (m memberMatcher) match(name string) bool {
+	return m.re.MatchString(name) != m.negate
+}
+
+// negativeLookahead 匹配 ACL4SSR 常用的 ^(?!.*(A|B)).*$ 排除写法，Go 的正则不支持零宽断言
+var negativeLookahead = regexp.MustCompile(`^\^\(\?!\.\*(.+)\)\.\*\$$`)
+
+// compileMember 编译成员正则，不支持的否定断言转换为取反匹配
+func compileMember(pattern string) (memberMatcher, error) {
+	re, err := regexp.Compile(pattern)
+	if err == nil {
+		return memberMatcher{re: re}, nil
+	}
+
+	if m := negativeLookahead.FindStringSubmatch(pattern); m != nil {
+		if inner, innerErr := regexp.Compile(m[1]); innerErr == nil {
+			return memberMatcher{re: inner, negate: true}, nil
+		}
+	}
+	return memberMatcher{}, err
+}
@@ -0,0 +1,184 @@
+package acl
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	content := `[custom]
+; 注释
+ruleset=🎯 全球直连,[]GEOIP,CN,no-resolve
+ruleset=🐟 漏网之鱼,[]FINAL
+ruleset=🚀 节点选择,https://example.com/rules/proxy.list,86400
+ruleset=🛑 广告拦截,clash-classic:https://example.com/rules/ads.yaml
+ruleset=🎯 全球直连,clash-domain:rules/direct.yaml,3600
+ruleset=,https://example.com/missing-group.list
+ruleset=无效内联,[]
+custom_proxy_group=🚀 节点选择` + "`select`[]♻️ 自动选择`[]DIRECT`.*" + `
+custom_proxy_group=♻️ 自动选择` + "`url-test`.*`http://www.gstatic.com/generate_204`300,,50" + `
+custom_proxy_group=🇭🇰 香港节点` + "`fallback`(港|HK)`http://www.gstatic.com/generate_204`600" + `
+custom_proxy_group=缺少测速参数` + "`url-test`.*" + `
+custom_proxy_group=无效成员` + "`select`(" + `
+enable_rule_generator=true
+unknown_key=value
+`
+	got := Parse(content)
+
+	wantRulesets := []Ruleset{
+		{Group: "🎯 全球直连", Rules: []Rule{{Type: "GEOIP", Value: "CN", Options: []string{"no-resolve"}}}},
+		{Group: "🐟 漏网之鱼", Rules: []Rule{{Type: "MATCH"}}},
+		{Group: "🚀 节点选择", Source: "https://example.com/rules/proxy.list", Interval: 86400},
+		{Group: "🛑 广告拦截", Source: "https://example.com/rules/ads.yaml", Format: "clash-classic"},
+		{Group: "🎯 全球直连", Source: "rules/direct.yaml", Format: "clash-domain", Interval: 3600},
+	}
+	if !reflect.DeepEqual(got.Rulesets, wantRulesets) {
+		t.Errorf("ruleset\n得到 %+v\n期望 %+v", got.Rulesets, wantRulesets)
+	}
+
+	wantGroups := []ProxyGroup{
+		{Name: "🚀 节点选择", Type: "select", Members: []string{"[]♻️ 自动选择", "[]DIRECT", ".*"}},
+		{Name: "♻️ 自动选择", Type: "url-test", Members: []string{".*"}, URL: "http://www.gstatic.com/generate_204", Interval: 300, Tolerance: 50},
+		{Name: "🇭🇰 香港节点", Type: "fallback", Members: []string{"(港|HK)"}, URL: "http://www.gstatic.com/generate_204", Interval: 600},
+	}
+	if !reflect.DeepEqual(got.ProxyGroups, wantGroups) {
+		t.Errorf("custom_proxy_group\n得到 %+v\n期望 %+v", got.ProxyGroups, wantGroups)
+	}
+	if !got.EnableRuleGenerator {
+		t.Error("enable_rule_generator 应为 true")
+	}
+
+	if Parse("enable_rule_generator=false").EnableRuleGenerator {
+		t.Error("enable_rule_generator=false 未生效")
+	}
+	if !Parse("").EnableRuleGenerator {
+		t.Error("enable_rule_generator 默认应为 true")
+	}
+}
+
+func TestParseRule(t *testing.T) {
+	tests := []struct {
+		line string
+		want Rule
+		ok   bool
+	}{
+		{"DOMAIN-SUFFIX,google.com", Rule{Type: "DOMAIN-SUFFIX", Value: "google.com"}, true},
+		{"host-suffix, google.com ,Proxy", Rule{Type: "DOMAIN-SUFFIX", Value: "google.com"}, true},
+		{"IP-CIDR,10.0.0.0/8,no-resolve", Rule{Type: "IP-CIDR", Value: "10.0.0.0/8", Options: []string{"no-resolve"}}, true},
+		{"IP6-CIDR,2001:db8::/32,NO-RESOLVE", Rule{Type: "IP-CIDR6", Value: "2001:db8::/32", Options: []string{"no-resolve"}}, true},
+		{"FINAL,Proxy", Rule{Type: "MATCH"}, true},
+		{"DOMAIN", Rule{}, false},
+		{"DOMAIN,", Rule{}, false},
+		{"", Rule{}, false},
+	}
+	for _, tt := range tests {
+		got, ok := ParseRule(tt.line)
+		if ok != tt.ok || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseRule(%q) 得到 %+v, %v，期望 %+v, %v", tt.line, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestParseRuleList(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		format  string
+		want    []Rule
+	}{
+		{
+			name:    "Surge 列表",
+			content: "# 注释\n// 注释\nDOMAIN-SUFFIX,google.com\n\nIP-CIDR,1.1.1.1/32,no-resolve\nFINAL,DIRECT\n",
+			want: []Rule{
+				{Type: "DOMAIN-SUFFIX", Value: "google.com"},
+				{Type: "IP-CIDR", Value: "1.1.1.1/32", Options: []string{"no-resolve"}},
+			},
+		},
+		{
+			name:    "QuanX 列表",
+			content: "HOST-SUFFIX,google.com,Proxy\nHOST-KEYWORD,youtube,Proxy\n",
+			format:  "quanx",
+			want: []Rule{
+				{Type: "DOMAIN-SUFFIX", Value: "google.com"},
+				{Type: "DOMAIN-KEYWORD", Value: "youtube"},
+			},
+		},
+		{
+			name:    "未指定格式的 Clash 规则集",
+			content: "payload:\n  - DOMAIN-SUFFIX,google.com\n  - 'PROCESS-NAME,curl'\n",
+			want: []Rule{
+				{Type: "DOMAIN-SUFFIX", Value: "google.com"},
+				{Type: "PROCESS-NAME", Value: "curl"},
+			},
+		},
+		{
+			name:    "clash-classic",
+			content: "payload:\n  - \"DOMAIN,ads.example.com\"\n  - GEOIP,CN\n",
+			format:  "clash-classic",
+			want: []Rule{
+				{Type: "DOMAIN", Value: "ads.example.com"},
+				{Type: "GEOIP", Value: "CN"},
+			},
+		},
+		{
+			name:    "clash-domain",
+			content: "payload:\n  - '+.google.com'\n  - .youtube.com\n  - www.example.com\n  - '*.wildcard.com'\n",
+			format:  "clash-domain",
+			want: []Rule{
+				{Type: "DOMAIN-SUFFIX", Value: "google.com"},
+				{Type: "DOMAIN-SUFFIX", Value: "youtube.com"},
+				{Type: "DOMAIN", Value: "www.example.com"},
+			},
+		},
+		{
+			name:    "clash-ipcidr",
+			content: "payload:\n  - 10.0.0.0/8\n  - '2001:db8::/32'\n",
+			format:  "clash-ipcidr",
+			want: []Rule{
+				{Type: "IP-CIDR", Value: "10.0.0.0/8", Options: []string{"no-resolve"}},
+				{Type: "IP-CIDR6", Value: "2001:db8::/32", Options: []string{"no-resolve"}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ParseRuleList(tt.content, tt.format); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("得到 %+v\n期望 %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProxyGroupResolve(t *testing.T) {
+	names := []string{"香港 01", "HK 02", "日本 01", "美国 01"}
+	tests := []struct {
+		name    string
+		members []string
+		want    []string
+	}{
+		{"直接引用和正则", []string{"[]♻️ 自动选择", "日本", "[]DIRECT"}, []string{"♻️ 自动选择", "日本 01", "DIRECT"}},
+		{"去重", []string{"港|HK", ".*"}, []string{"香港 01", "HK 02", "日本 01", "美国 01"}},
+		{"否定断言", []string{"^(?!.*(港|HK)).*$"}, []string{"日本 01", "美国 01"}},
+		{"否定断言和其他成员", []string{"^(?!.*(日本|美国|港)).*$", "[]DIRECT"}, []string{"HK 02", "DIRECT"}},
+		{"没有匹配", []string{"新加坡"}, []string{"DIRECT"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := ProxyGroup{Name: "test", Type: "select", Members: tt.members}
+			if got := g.Resolve(names); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("得到 %q，期望 %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompileMember(t *testing.T) {
+	if _, err := compileMember("^(?!.*(港|HK)).*$"); err != nil {
+		t.Errorf("否定断言应转换为取反匹配: %v", err)
+	}
+	for _, pattern := range []string{"(", "^(?!.*(港).*$", "(?<=港)01"} {
+		if _, err := compileMember(pattern); err == nil {
+			t.Errorf("compileMember(%q) 应失败", pattern)
+		}
+	}
+}
@@ -0,0 +1,229 @@
+package acl
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// retryInterval 加载 ini 配置失败后，在该时间内不再重新请求
+const retryInterval = time.Minute
+
+// Loader 读取 ini 配置及其引用的规则列表，远程内容按更新间隔缓存在内存中
+type Loader struct {
+	ttl    time.Duration
+	client *http.Client
+
+	mutex sync.Mutex
+	cache map[string]cacheEntry
+
+	templateMutex sync.Mutex
+	template      *Template
+	templateTime  time.Time
+	templateFrom  string
+	// loading 非空时表示正在加载，加载完成后关闭
+	loading chan struct{}
+	// failErr/failedAt/failedFrom 最近一次加载失败的原因、时间和来源
+	failErr    error
+	failedAt   time.Time
+	failedFrom string
+}
+
+type cacheEntry struct {
+	content string
+	fetched time.Time
+}
+
+// NewLoader 创建加载器，ttl 为远程配置和规则列表的默认更新间隔，不大于0时为24小时
+func NewLoader(ttl time.Duration) *Loader {
+	if ttl <= 0 {
+		ttl = 24 * time.Hour
+	}
+	return &Loader{
+		ttl:    ttl,
+		client: &http.Client{Timeout: 30 * time.Second},
+		cache:  make(map[string]cacheEntry),
+	}
+}
+
+// Load 加载 ini 配置，来源可以是URL或本地路径。
+// 解析结果在更新间隔内直接复用；重新加载失败时继续使用上一次成功的结果，
+// 失败后 retryInterval 内不再重新请求。同一时间只有一个请求在加载，其他请求使用旧结果或等待加载完成
+func (l *Loader) Load(source string) (*Template, error) {
+	for {
+		l.templateMutex.Lock()
+		var cached *Template
+		if l.templateFrom == source {
+			cached = l.template
+		}
+		if cached != nil && time.Since(l.templateTime) < l.ttl {
+			l.templateMutex.Unlock()
+			return cached, nil
+		}
+		if l.failedFrom == source && l.failErr != nil && time.Since(l.failedAt) < retryInterval {
+			err := l.failErr
+			l.templateMutex.Unlock()
+			if cached != nil {
+				return cached, nil
+			}
+			return nil, err
+		}
+		if l.loading != nil {
+			if cached != nil {
+				l.templateMutex.Unlock()
+				return cached, nil
+			}
+			wait := l.loading
+			l.templateMutex.Unlock()
+			<-wait
+			continue
+		}
+
+		done := make(chan struct{})
+		l.loading = done
+		l.templateMutex.Unlock()
+
+		// 在锁外请求网络，避免阻塞使用缓存的请求
+		t, err := l.load(source)
+
+		l.templateMutex.Lock()
+		l.loading = nil
+		close(done)
+		if err != nil {
+			l.failErr, l.failedAt, l.failedFrom = err, time.Now(), source
+			l.templateMutex.Unlock()
+			if cached != nil {
+				log.Printf("重新加载订阅转换配置失败，继续使用缓存: %v", err)
+				return cached, nil
+			}
+			return nil, err
+		}
+		l.template, l.templateTime, l.templateFrom = t, time.Now(), source
+		l.failErr = nil
+		l.templateMutex.Unlock()
+		return t, nil
+	}
+}
+
+func (l *Loader) load(source string) (*Template, error) {
+	content, err := l.read(source, l.ttl)
+	if err != nil {
+		return nil, fmt.Errorf("读取配置文件失败: %w", err)
+	}
+
+	t := Parse(content)
+
+	// 并发读取规则列表，单个列表失败时跳过，不影响其他规则
+	var wg sync.WaitGroup
+	for i := range t.Rulesets {
+		rs := &t.Rulesets[i]
+		if rs.Source == "" {
+			continue
+		}
+		rs.Source = resolvePath(source, rs.Source)
+
+		ttl := l.ttl
+		if rs.Interval > 0 {
+			ttl = time.Duration(rs.Interval) * time.Second
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			list, err := l.read(rs.Source, ttl)
+			if err != nil {
+				log.Printf("读取规则列表 %s 失败: %v", rs.Source, err)
+				return
+			}
+			rs.Rules = ParseRuleList(list, rs.Format)
+		}()
+	}
+	wg.Wait()
+
+	log.Printf("已加载订阅转换配置: %d 个代理组, %d 组规则", len(t.ProxyGroups), len(t.Rulesets))
+	return t, nil
+}
+
+// read 读取URL或本地文件内容，远程内容在 ttl 内使用缓存，请求失败时返回过期的缓存
+func (l *Loader) read(source string, ttl time.Duration) (string, error) {
+	if !isRemote(source) {
+		data, err := os.ReadFile(source)
+		return string(data), err
+	}
+
+	l.mutex.Lock()
+	entry, cached := l.cache[source]
+	l.mutex.Unlock()
+	if cached && time.Since(entry.fetched) < ttl {
+		return entry.content, nil
+	}
+
+	content, err := l.fetch(source)
+	if err != nil {
+		if cached {
+			log.Printf("获取 %s 失败，使用缓存内容: %v", source, err)
+			return entry.content, nil
+		}
+		return "", err
+	}
+
+	l.mutex.Lock()
+	l.cache[source] = cacheEntry{content: content, fetched: time.Now()}
+	l.mutex.Unlock()
+	return content, nil
+}
+
+func (l *Loader) fetch(source string) (string, error) {
+	resp, err := l.client.Get(source)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("返回错误状态码: %d", resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	return string(data), err
+}
+
+func isRemote(source string) bool {
+	return strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://")
+}
+
+// resolvePath 将规则列表的相对路径解析为相对于 ini 配置所在位置的路径
+func resolvePath(base, ref string) string {
+	if isRemote(ref) || filepath.IsAbs(ref) {
+		return ref
+	}
+
+	if isRemote(base) {
+		baseURL, err := url.Parse(base)
+		if err != nil {
+			return ref
+		}
+		refURL, err := url.Parse(ref)
+		if err != nil {
+			return ref
+		}
+		return baseURL.ResolveReference(refURL).String()
+	}
+	// 本地路径优先相对于 ini 所在目录，不存在时相对于工作目录
+	if path := filepath.Join(filepath.Dir(base), ref); fileExists(path) {
+		return path
+	}
+	return ref
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
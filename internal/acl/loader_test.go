@@ -0,0 +1,59 @@
+package acl
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestLoaderCachesFailure(t *testing.T) {
+	var hits int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer srv.Close()
+
+	l := NewLoader(time.Hour)
+	for i := 0; i < 3; i++ {
+		if _, err := l.Load(srv.URL + "/config.ini"); err == nil {
+			t.Fatal("期望加载失败")
+		}
+	}
+	if got := atomic.LoadInt32(&hits); got != 1 {
+		t.Errorf("失败后重试间隔内请求了 %d 次，期望 1 次", got)
+	}
+}
+
+func TestLoaderLoadsOnceConcurrently(t *testing.T) {
+	var hits int32
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		<-release
+		w.Write([]byte("[custom]\ncustom_proxy_group=Proxy`select`.*\n"))
+	}))
+	defer srv.Close()
+
+	l := NewLoader(time.Hour)
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if tpl, err := l.Load(srv.URL + "/config.ini"); err != nil || len(tpl.ProxyGroups) != 1 {
+				t.Errorf("加载结果 %+v, %v", tpl, err)
+			}
+		}()
+	}
+	time.Sleep(100 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if got := atomic.LoadInt32(&hits); got != 1 {
+		t.Errorf("并发加载请求了 %d 次，期望 1 次", got)
+	}
+}
@@ -0,0 +1,96 @@
+package filter
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+
+	"sublinks/config"
+	"sublinks/internal/node"
+)
+
+// Filter 按名称、协议和服务器地址筛选节点，nil 表示不过滤
+type Filter struct {
+	include, exclude                 *regexp.Regexp
+	includeProtocol, excludeProtocol *regexp.Regexp
+	includeServer, excludeServer     *regexp.Regexp
+}
+
+// New 编译过滤规则，所有规则为空时返回nil
+func New(cfg config.FilterConfig) (*Filter, error) {
+	f := &Filter{}
+	fields := []struct {
+		name    string
+		pattern string
+		re      **regexp.Regexp
+	}{
+		{"include", cfg.Include, &f.include},
+		{"exclude", cfg.Exclude, &f.exclude},
+		{"include_protocol", cfg.IncludeProtocol, &f.includeProtocol},
+		{"exclude_protocol", cfg.ExcludeProtocol, &f.excludeProtocol},
+		{"include_server", cfg.IncludeServer, &f.includeServer},
+		{"exclude_server", cfg.ExcludeServer, &f.excludeServer},
+	}
+
+	empty := true
+	for _, field := range fields {
+		if field.pattern == "" {
+			continue
+		}
+		re, err := regexp.Compile(field.pattern)
+		if err != nil {
+			return nil, fmt.Errorf("过滤规则 %s 无效: %w", field.name, err)
+		}
+		*field.re = re
+		empty = false
+	}
+
+	if empty {
+		return nil, nil
+	}
+	return f, nil
+}
+
+// FromQuery 从请求参数读取过滤规则，参数名与配置文件相同
+func FromQuery(query url.Values) (*Filter, error) {
+	return New(config.FilterConfig{
+		Include:         query.Get("include"),
+		Exclude:         query.Get("exclude"),
+		IncludeProtocol: query.Get("include_protocol"),
+		ExcludeProtocol: query.Get("exclude_protocol"),
+		IncludeServer:   query.Get("include_server"),
+		ExcludeServer:   query.Get("exclude_server"),
+	})
+}
+
+// Match 判断节点是否保留：需匹配所有 include 规则且不匹配任何 exclude 规则
+func (f *Filter) Match(n *node.Node) bool {
+	if f == nil {
+		return true
+	}
+	return matches(f.include, f.exclude, n.Name) &&
+		matches(f.includeProtocol, f.excludeProtocol, string(n.Protocol)) &&
+		matches(f.includeServer, f.excludeServer, n.Server)
+}
+
+// Apply 返回保留的节点
+func (f *Filter) Apply(nodes []*node.Node) []*node.Node {
+	if f == nil {
+		return nodes
+	}
+
+	result := make([]*node.Node, 0, len(nodes))
+	for _, n := range nodes {
+		if f.Match(n) {
+			result = append(result, n)
+		}
+	}
+	return result
+}
+
+func matches(include, exclude *regexp.Regexp, value string) bool {
+	if include != nil && !include.MatchString(value) {
+		return false
+	}
+	return exclude == nil || !exclude.MatchString(value)
+}
@@ -0,0 +1,46 @@
+package handler
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequireAdmin 验证管理接口的凭据：Authorization: Bearer <admin_token>，
+// 或用户名为 admin_user、密码为 admin_token 的 Basic 认证。未配置 admin_token 时拒绝所有管理请求
+func (h *Handler) RequireAdmin() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if h.config.AdminToken == "" {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "未配置管理令牌，管理接口已禁用"})
+			return
+		}
+		if !h.validateAdmin(c.Request) {
+			c.Header("WWW-Authenticate", `Basic realm="sublinks"`)
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "未授权的访问"})
+			return
+		}
+		c.Next()
+	}
+}
+
+func (h *Handler) validateAdmin(r *http.Request) bool {
+	if username, password, ok := r.BasicAuth(); ok {
+		return tokenEqual(username, h.config.AdminUser) && tokenEqual(password, h.config.AdminToken)
+	}
+
+	auth := r.Header.Get("Authorization")
+	if len(auth) > len("Bearer ") && strings.EqualFold(auth[:len("Bearer ")], "Bearer ") {
+		return tokenEqual(strings.TrimSpace(auth[len("Bearer "):]), h.config.AdminToken)
+	}
+	return false
+}
+
+// tokenEqual 以固定时间比较令牌，避免通过响应时间猜测令牌；空令牌永远不匹配
+func tokenEqual(token, expected string) bool {
+	if token == "" || expected == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(token), []byte(expected)) == 1
+}
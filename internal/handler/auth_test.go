@@ -0,0 +1,75 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"sublinks/config"
+)
+
+func TestTokenEqual(t *testing.T) {
+	tests := []struct {
+		token, expected string
+		want            bool
+	}{
+		{"secret", "secret", true},
+		{"secret", "Secret", false},
+		{"secret", "secret2", false},
+		{"secre", "secret", false},
+		{"", "", false},
+		{"", "secret", false},
+		{"secret", "", false},
+	}
+	for _, tt := range tests {
+		if got := tokenEqual(tt.token, tt.expected); got != tt.want {
+			t.Errorf("tokenEqual(%q, %q) 得到 %v，期望 %v", tt.token, tt.expected, got, tt.want)
+		}
+	}
+}
+
+func TestRequireAdmin(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name       string
+		adminToken string
+		header     func(r *http.Request)
+		want       int
+	}{
+		{"未配置管理令牌", "", func(r *http.Request) { r.Header.Set("Authorization", "Bearer ") }, http.StatusForbidden},
+		{"缺少凭据", "adm", func(r *http.Request) {}, http.StatusUnauthorized},
+		{"Bearer", "adm", func(r *http.Request) { r.Header.Set("Authorization", "Bearer adm") }, http.StatusOK},
+		{"Bearer 大小写", "adm", func(r *http.Request) { r.Header.Set("Authorization", "bearer adm") }, http.StatusOK},
+		{"Bearer 错误", "adm", func(r *http.Request) { r.Header.Set("Authorization", "Bearer other") }, http.StatusUnauthorized},
+		{"Bearer 为空", "adm", func(r *http.Request) { r.Header.Set("Authorization", "Bearer ") }, http.StatusUnauthorized},
+		{"Basic", "adm", func(r *http.Request) { r.SetBasicAuth("admin", "adm") }, http.StatusOK},
+		{"Basic 用户名错误", "adm", func(r *http.Request) { r.SetBasicAuth("root", "adm") }, http.StatusUnauthorized},
+		{"Basic 密码错误", "adm", func(r *http.Request) { r.SetBasicAuth("admin", "other") }, http.StatusUnauthorized},
+		{"其他认证方式", "adm", func(r *http.Request) { r.Header.Set("Authorization", "Token adm") }, http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &Handler{config: &config.Config{AdminUser: "admin", AdminToken: tt.adminToken}}
+			r := gin.New()
+			r.GET("/api/report", h.RequireAdmin(), func(c *gin.Context) {
+				c.Status(http.StatusOK)
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/api/report", nil)
+			tt.header(req)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.want {
+				t.Errorf("状态码 %d，期望 %d", w.Code, tt.want)
+			}
+			if w.Code == http.StatusUnauthorized && w.Header().Get("WWW-Authenticate") == "" {
+				t.Error("401 响应缺少 WWW-Authenticate")
+			}
+		})
+	}
+}
@@ -1,7 +1,8 @@
 package handler
 
 import (
-	"encoding/base64"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
 	"strings"
@@ -9,34 +10,37 @@ import (
 	"github.com/gin-gonic/gin"
 
 	"sublinks/config"
+	"sublinks/internal/filter"
 	"sublinks/internal/service"
+	"sublinks/internal/share"
 )
 
 type Handler struct {
 	merger    *service.NodeMerger
 	converter *service.Converter
 	notifier  *service.Notifier
+	shares    *share.Store
 	config    *config.Config
 }
 
-func NewHandler(cfg *config.Config) *Handler {
+// NewHandler 创建处理器，分享链接记录无法加载时返回错误
+func NewHandler(cfg *config.Config) (*Handler, error) {
+	shares, err := share.NewStore(cfg.ShareSecret, cfg.ShareFile)
+	if err != nil {
+		return nil, fmt.Errorf("加载分享链接失败: %w", err)
+	}
+
 	return &Handler{
-		merger:    service.NewNodeMerger(cfg.MainData),
-		converter: service.NewConverter(cfg.Subconverter, cfg.SubConfig, cfg.SUBUpdateTime),
+		merger:    service.NewNodeMerger(cfg),
+		converter: service.NewConverter(cfg),
 		notifier:  service.NewNotifier(cfg.TGBotToken, cfg.TGChatID, cfg.TGNotifyLevel),
+		shares:    shares,
 		config:    cfg,
-	}
+	}, nil
 }
 
 // AddSubscribe 添加订阅
 func (h *Handler) AddSubscribe(c *gin.Context) {
-	// 验证token
-	token := c.Query("token")
-	if !h.validateToken(token, c.Request.URL.Path) {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "未授权的访问"})
-		return
-	}
-
 	var req struct {
 		URL string `json:"url"`
 	}
@@ -45,9 +49,13 @@ func (h *Handler) AddSubscribe(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求数据"})
 		return
 	}
+	if err := validateSourceURL(req.URL); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
 	if err := config.AddSubscribeURL(req.URL); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "添加订阅失败"})
+		respondSourceError(c, err, "添加订阅失败")
 		return
 	}
 
@@ -56,13 +64,6 @@ func (h *Handler) AddSubscribe(c *gin.Context) {
 
 // RemoveSubscribe 删除订阅
 func (h *Handler) RemoveSubscribe(c *gin.Context) {
-	// 验证token
-	token := c.Query("token")
-	if !h.validateToken(token, c.Request.URL.Path) {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "未授权的访问"})
-		return
-	}
-
 	var req struct {
 		URL string `json:"url"`
 	}
@@ -73,7 +74,7 @@ func (h *Handler) RemoveSubscribe(c *gin.Context) {
 	}
 
 	if err := config.RemoveSubscribeURL(req.URL); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "删除订阅失败"})
+		respondSourceError(c, err, "删除订阅失败")
 		return
 	}
 
@@ -82,64 +83,135 @@ func (h *Handler) RemoveSubscribe(c *gin.Context) {
 
 // ListSubscribe 列出所有订阅
 func (h *Handler) ListSubscribe(c *gin.Context) {
-	// 验证token
-	token := c.Query("token")
-	if !h.validateToken(token, c.Request.URL.Path) {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "未授权的访问"})
+	urls := config.GetAllSubscribeURLs()
+	c.JSON(http.StatusOK, gin.H{"urls": urls})
+}
+
+// GetReport 查看最近一次合并中无法解析的节点，?user= 指定用户，为空时查看使用全部订阅源的合并
+func (h *Handler) GetReport(c *gin.Context) {
+	report, ok := h.lastReport(c)
+	if !ok {
 		return
 	}
+	c.JSON(http.StatusOK, report)
+}
 
-	urls := config.GetAllSubscribeURLs()
-	c.JSON(http.StatusOK, gin.H{"urls": urls})
+// GetUserInfo 查看各订阅源的流量和到期信息，以及返回给客户端的汇总结果，?user= 的含义与 GetReport 相同
+func (h *Handler) GetUserInfo(c *gin.Context) {
+	report, ok := h.lastReport(c)
+	if !ok {
+		return
+	}
+	sources := make([]gin.H, 0, len(report.Sources))
+	for _, s := range report.Sources {
+		sources = append(sources, gin.H{"name": s.Name, "url": s.URL, "userinfo": s.UserInfo})
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"time":     report.Time,
+		"userinfo": report.UserInfo,
+		"sources":  sources,
+	})
 }
 
-func (h *Handler) HandleSubscribe(w http.ResponseWriter, r *http.Request) {
-	// 验证token
-	token := r.URL.Query().Get("token")
-	if !h.validateToken(token, r.URL.Path) {
+// lastReport 返回 ?user= 指定用户最近一次合并的统计信息，指定的用户还没有合并过时返回404
+func (h *Handler) lastReport(c *gin.Context) (service.MergeReport, bool) {
+	user := c.Query("user")
+	report, ok := h.merger.LastReport(user)
+	if !ok && user != "" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "用户 " + user + " 还没有获取过订阅"})
+		return service.MergeReport{}, false
+	}
+	return report, true
+}
+
+// HandleSubscribe 返回订阅内容。token 来自 ?token= 参数或路径（如 /{token}），可以是分享链接令牌；
+// segment 为路径中令牌之后的部分（如 /sub/clash、/{token}/alice），可以是输出格式或用户名，可为空
+func (h *Handler) HandleSubscribe(w http.ResponseWriter, r *http.Request, token, segment string) {
+	// 验证token，不像令牌的路径（如 /favicon.ico、/api/xxx）直接返回404，不发送通知
+	sub, ok := h.authenticate(token)
+	if !ok {
+		if !looksLikeToken(token) {
+			http.NotFound(w, r)
+			return
+		}
 		h.handleUnauthorized(w, r)
 		return
 	}
+	user := sub.user
+
+	// 路径中的用户名：my_token 可以查看任意用户的订阅，用户令牌和分享链接只能指定自己的用户
+	pathTarget, profile := splitSegment(segment)
+	if profile != "" {
+		if sub.owner {
+			u, found := config.FindUserByName(profile)
+			if !found {
+				http.Error(w, "不支持的订阅格式或用户不存在", http.StatusNotFound)
+				return
+			}
+			user = &u
+		} else if user == nil || user.Name != profile {
+			h.handleUnauthorized(w, r)
+			return
+		}
+	}
 
 	// 打印客户端信息
 	log.Printf("客户端请求订阅，UserAgent: %s", r.UserAgent())
 
-	// 合并节点
-	mergedContent, err := h.merger.MergeNodes()
+	// 用户可使用的订阅源、过滤规则和默认格式
+	mergeOpts := service.AllSourcesOptions()
+	defaultTarget := ""
+	var userFilter *filter.Filter
+	if user != nil {
+		log.Printf("用户: %s", user.Name)
+		mergeOpts = service.UserMergeOptions(*user)
+		defaultTarget = user.Target
+		var err error
+		if userFilter, err = filter.New(user.Filter); err != nil {
+			log.Printf("用户 %s 的过滤规则无效，已忽略: %v", user.Name, err)
+		}
+	}
+
+	// 确定输出格式
+	clientType, ok := h.resolveTarget(r, pathTarget, defaultTarget)
+	if !ok {
+		http.Error(w, "不支持的订阅格式", http.StatusBadRequest)
+		return
+	}
+	log.Printf("输出格式: %s", clientType)
+
+	// 请求中的过滤规则
+	requestFilter, err := filter.FromQuery(r.URL.Query())
 	if err != nil {
-		log.Printf("节点合并失败: %v", err)
-		http.Error(w, "节点合并失败", http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	if len(mergedContent) == 0 {
-		log.Printf("警告: 合并后的节点内容为空")
-	} else {
-		log.Printf("合并后的节点内容大小: %d字节", len(mergedContent))
+	// 合并节点，用户和请求的过滤规则在重命名之前生效
+	mergeOpts.ReservedNames = h.converter.ReservedNames()
+	mergeOpts.Filters = []*filter.Filter{userFilter, requestFilter}
+	nodes, report, err := h.merger.MergeNodes(mergeOpts)
+	if err != nil {
+		log.Printf("节点合并失败: %v", err)
+		http.Error(w, "节点合并失败", http.StatusInternalServerError)
+		return
 	}
 
-	// 检测客户端类型
-	clientType := h.converter.DetectClientType(r.UserAgent())
-	log.Printf("检测到客户端类型: %s", clientType)
-
-	// 浏览器直接访问时，应该解码base64
-	if strings.Contains(strings.ToLower(r.UserAgent()), "mozilla") &&
-		!strings.Contains(strings.ToLower(r.UserAgent()), "clash") &&
-		!strings.Contains(strings.ToLower(r.UserAgent()), "v2ray") &&
-		!strings.Contains(strings.ToLower(r.UserAgent()), "sing") {
-		log.Printf("检测到浏览器访问，尝试解码base64内容")
-		// 如果是浏览器直接访问，解码base64
-		decoded, err := base64.StdEncoding.DecodeString(mergedContent)
-		if err == nil {
-			mergedContent = string(decoded)
-			log.Printf("成功解码base64内容")
-		} else {
-			log.Printf("base64解码失败: %v", err)
-		}
+	if len(nodes) == 0 {
+		log.Printf("合并后的节点列表为空")
+		http.Error(w, service.ErrNoNodes.Error(), http.StatusServiceUnavailable)
+		return
 	}
+	log.Printf("合并后的节点数量: %d", len(nodes))
 
 	// 转换格式
-	convertedContent, err := h.converter.Convert(mergedContent, clientType)
+	opts := service.ConvertOptions{SubscriptionURL: requestURL(r)}
+	convertedContent, err := h.converter.Convert(nodes, clientType, opts)
+	if errors.Is(err, service.ErrNoNodes) {
+		log.Printf("没有可转换为%s格式的节点", clientType)
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
 	if err != nil {
 		log.Printf("订阅转换失败: %v", err)
 		http.Error(w, "订阅转换失败", http.StatusInternalServerError)
@@ -152,8 +224,21 @@ func (h *Handler) HandleSubscribe(w http.ResponseWriter, r *http.Request) {
 		log.Printf("转换后的内容大小: %d字节", len(convertedContent))
 	}
 
+	// 订阅内容生成成功后才计入分享链接的使用次数
+	if sub.share != nil {
+		if err := h.shares.Consume(*sub.share); err != nil {
+			log.Printf("分享链接 %s 计入使用次数失败: %v", sub.share.ID, err)
+			if errors.Is(err, share.ErrUsedUp) || errors.Is(err, share.ErrRevoked) {
+				h.handleUnauthorized(w, r)
+			} else {
+				http.Error(w, "记录分享链接使用次数失败", http.StatusInternalServerError)
+			}
+			return
+		}
+	}
+
 	// 设置响应头
-	headers := h.converter.GetResponseHeaders(h.config.FileName)
+	headers := h.converter.GetResponseHeaders(h.config.FileName, clientType, report.UserInfo)
 	for key, value := range headers {
 		w.Header().Set(key, value)
 		log.Printf("设置响应头: %s=%s", key, value)
@@ -166,6 +251,9 @@ func (h *Handler) HandleSubscribe(w http.ResponseWriter, r *http.Request) {
 			clientIP = r.RemoteAddr
 		}
 		additionalData := "UA: " + r.UserAgent()
+		if user != nil {
+			additionalData += "\n用户: " + user.Name
+		}
 		h.notifier.SendMessage("#获取订阅", clientIP, additionalData)
 	}
 
@@ -174,6 +262,46 @@ func (h *Handler) HandleSubscribe(w http.ResponseWriter, r *http.Request) {
 	log.Printf("成功返回订阅内容给客户端")
 }
 
+// splitSegment 判断路径中令牌之后的部分是输出格式还是用户名
+func splitSegment(segment string) (target, profile string) {
+	if segment == "" {
+		return "", ""
+	}
+	if _, ok := service.ParseTarget(segment); ok {
+		return segment, ""
+	}
+	return "", segment
+}
+
+// resolveTarget 确定输出格式，优先级为路径、target 参数、用户默认格式、UA识别
+func (h *Handler) resolveTarget(r *http.Request, pathTarget, defaultTarget string) (service.ConverterType, bool) {
+	target := firstNonEmpty(pathTarget, r.URL.Query().Get("target"), defaultTarget)
+	if target != "" {
+		return service.ParseTarget(target)
+	}
+
+	clientType := h.converter.DetectClientType(r.UserAgent())
+	log.Printf("根据UserAgent识别客户端类型: %s", clientType)
+	return clientType, true
+}
+
+// requestURL 还原客户端访问的完整地址，兼容反向代理
+func requestURL(r *http.Request) string {
+	return baseURL(r) + r.URL.RequestURI()
+}
+
+// baseURL 还原客户端访问的协议和主机，如 https://example.com
+func baseURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	if proto := r.Header.Get("X-Forwarded-Proto"); proto != "" {
+		scheme = proto
+	}
+	return scheme + "://" + r.Host
+}
+
 func (h *Handler) handleUnauthorized(w http.ResponseWriter, r *http.Request) {
 	if h.notifier.ShouldNotify(false) {
 		clientIP := r.Header.Get("CF-Connecting-IP")
@@ -186,10 +314,80 @@ func (h *Handler) handleUnauthorized(w http.ResponseWriter, r *http.Request) {
 	w.Write([]byte(nginxWelcomePage))
 }
 
-func (h *Handler) validateToken(token, path string) bool {
-	return token == h.config.MyToken ||
-		strings.HasPrefix(path, "/"+h.config.MyToken) ||
-		strings.Contains(path, "/"+h.config.MyToken+"?")
+// subscriber 订阅请求的身份
+type subscriber struct {
+	// user 令牌所属或分享链接指定的用户，为空时使用全部订阅源
+	user *config.User
+	// owner 使用 my_token 访问，可以查看任意用户的订阅
+	owner bool
+	// share 通过分享链接访问时的令牌内容，订阅内容生成后才计入使用次数
+	share *share.Claims
+}
+
+// authenticate 验证订阅请求的令牌：先验证分享链接，再验证 my_token 和用户令牌，停用的用户视为未授权
+func (h *Handler) authenticate(token string) (subscriber, bool) {
+	claims, err := h.shares.Verify(token)
+	if err == nil {
+		sub := subscriber{share: &claims}
+		if claims.Profile != "" {
+			user, ok := config.FindUserByName(claims.Profile)
+			if !ok || user.Disabled {
+				log.Printf("分享链接 %s 的用户 %s 不存在或已停用", claims.ID, claims.Profile)
+				return subscriber{}, false
+			}
+			sub.user = &user
+		}
+		return sub, true
+	}
+	if !errors.Is(err, share.ErrNotShareToken) {
+		log.Printf("分享链接验证失败: %v", err)
+		return subscriber{}, false
+	}
+
+	if h.validateToken(token) {
+		return subscriber{owner: true}, true
+	}
+	user, ok := config.FindUserByToken(token)
+	if !ok || user.Disabled {
+		return subscriber{}, false
+	}
+	return subscriber{user: &user}, true
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// reservedPaths 路由使用的路径前缀，不能作为令牌或用户名
+var reservedPaths = []string{"api", "sub"}
+
+// isReservedPath 判断名称是否为路由使用的路径前缀
+func isReservedPath(name string) bool {
+	for _, p := range reservedPaths {
+		if strings.EqualFold(name, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// looksLikeToken 判断路径或参数中的值是否可能是令牌：不为空、不是保留路径，
+// 且除分享令牌外不包含 "."，以排除 favicon.ico、robots.txt 等文件请求
+func looksLikeToken(token string) bool {
+	if token == "" || isReservedPath(token) {
+		return false
+	}
+	return strings.HasPrefix(token, share.TokenPrefix) || !strings.Contains(token, ".")
+}
+
+// validateToken 判断是否为 my_token，令牌必须完整匹配
+func (h *Handler) validateToken(token string) bool {
+	return tokenEqual(token, h.config.MyToken)
 }
 
 const nginxWelcomePage = `
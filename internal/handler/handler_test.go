@@ -0,0 +1,172 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"sublinks/config"
+	"sublinks/internal/service"
+	"sublinks/internal/share"
+)
+
+func TestLooksLikeToken(t *testing.T) {
+	tests := []struct {
+		token string
+		want  bool
+	}{
+		{"", false},
+		{"favicon.ico", false},
+		{"robots.txt", false},
+		{"api", false},
+		{"SUB", false},
+		{"auto", true},
+		{"0123456789abcdef", true},
+		{"s.payload.signature", true},
+	}
+	for _, tt := range tests {
+		if got := looksLikeToken(tt.token); got != tt.want {
+			t.Errorf("looksLikeToken(%q) 得到 %v，期望 %v", tt.token, got, tt.want)
+		}
+	}
+}
+
+func TestHandleSubscribeUnknownPath(t *testing.T) {
+	shares, err := share.NewStore("", t.TempDir()+"/shares.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := &Handler{
+		notifier: service.NewNotifier("", "", 0),
+		shares:   shares,
+		config:   &config.Config{MyToken: "owner"},
+	}
+
+	tests := []struct {
+		name           string
+		token, segment string
+		wantNotFound   bool
+	}{
+		{"favicon", "favicon.ico", "", true},
+		{"robots", "robots.txt", "", true},
+		{"未知管理接口", "api", "unknown", true},
+		{"缺少令牌", "", "alice", true},
+		{"错误令牌", "wrong", "", false},
+		{"无效分享令牌", "s.payload.signature", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			h.HandleSubscribe(w, httptest.NewRequest(http.MethodGet, "/", nil), tt.token, tt.segment)
+
+			if tt.wantNotFound {
+				if w.Code != http.StatusNotFound {
+					t.Errorf("状态码 %d，期望 404", w.Code)
+				}
+				return
+			}
+			if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "Welcome to nginx!") {
+				t.Errorf("状态码 %d，期望返回 nginx 欢迎页", w.Code)
+			}
+		})
+	}
+}
+
+func TestValidateUser(t *testing.T) {
+	tests := []struct {
+		user    config.User
+		wantErr bool
+	}{
+		{config.User{Name: "alice"}, false},
+		{config.User{Name: "alice", Token: "0123456789abcdef"}, false},
+		{config.User{}, true},
+		{config.User{Name: "clash"}, true},
+		{config.User{Name: "Sing-Box"}, true},
+		{config.User{Name: "api"}, true},
+		{config.User{Name: "sub"}, true},
+		{config.User{Name: "a/b"}, true},
+		{config.User{Name: "alice", Token: "s.abc"}, true},
+		{config.User{Name: "alice", Token: "favicon.ico"}, true},
+		{config.User{Name: "alice", Token: "api"}, true},
+		{config.User{Name: "alice", Target: "unknown"}, true},
+	}
+	for _, tt := range tests {
+		err := validateUser(tt.user)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("validateUser(%+v) 得到 %v，期望出错 %v", tt.user, err, tt.wantErr)
+		}
+	}
+}
+
+func TestShareUseCountedAfterConversion(t *testing.T) {
+	dir := t.TempDir()
+	config.GlobalConfig.UsersFile = filepath.Join(dir, "users.json")
+	if err := config.LoadUsers(); err != nil {
+		t.Fatal(err)
+	}
+	for _, u := range []config.User{{Name: "alice"}, {Name: "bob", Disabled: true}} {
+		if _, err := config.AddUser(u); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Cleanup(func() {
+		config.GlobalConfig.UsersFile = ""
+		config.LoadUsers()
+	})
+
+	shares, err := share.NewStore("secret", filepath.Join(dir, "shares.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	newHandler := func(mainData string) *Handler {
+		cfg := &config.Config{MyToken: "owner", MainData: mainData}
+		return &Handler{
+			merger:    service.NewNodeMerger(cfg),
+			converter: service.NewConverter(cfg),
+			notifier:  service.NewNotifier("", "", 0),
+			shares:    shares,
+			config:    cfg,
+		}
+	}
+
+	tests := []struct {
+		name     string
+		profile  string
+		mainData string
+		wantCode int
+		wantUses int
+	}{
+		{"用户已停用", "bob", "trojan://secret@example.com:443#A", http.StatusOK, 0},
+		{"没有节点", "alice", "", http.StatusServiceUnavailable, 0},
+		{"格式错误", "alice", "trojan://secret@example.com:443#A", http.StatusBadRequest, 0},
+		{"成功", "alice", "trojan://secret@example.com:443#A", http.StatusOK, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, link, err := shares.Issue(time.Now().Add(time.Hour), tt.profile, 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			target := "raw"
+			if tt.wantCode == http.StatusBadRequest {
+				target = "unknown"
+			}
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, "/?target="+target, nil)
+			newHandler(tt.mainData).HandleSubscribe(w, r, token, "")
+			if w.Code != tt.wantCode {
+				t.Errorf("状态码 %d，期望 %d", w.Code, tt.wantCode)
+			}
+
+			for _, l := range shares.Links() {
+				if l.ID == link.ID && l.Uses != tt.wantUses {
+					t.Errorf("使用次数 %d，期望 %d", l.Uses, tt.wantUses)
+				}
+			}
+		})
+	}
+}
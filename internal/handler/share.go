@@ -0,0 +1,80 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"sublinks/config"
+	"sublinks/internal/share"
+)
+
+// ListShares 列出所有分享链接及使用次数
+func (h *Handler) ListShares(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"links": h.shares.Links()})
+}
+
+// IssueShare 签发分享链接，expires_in（秒）和 expires_at（Unix 时间戳）二选一
+func (h *Handler) IssueShare(c *gin.Context) {
+	var req struct {
+		ExpiresIn int64  `json:"expires_in"`
+		ExpiresAt int64  `json:"expires_at"`
+		Profile   string `json:"profile"`
+		MaxUses   int    `json:"max_uses"`
+	}
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求数据"})
+		return
+	}
+
+	expires := time.Unix(req.ExpiresAt, 0)
+	if req.ExpiresIn > 0 {
+		expires = time.Now().Add(time.Duration(req.ExpiresIn) * time.Second)
+	}
+	if !expires.After(time.Now()) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "过期时间必须晚于当前时间"})
+		return
+	}
+	if req.MaxUses < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "max_uses 不能为负数"})
+		return
+	}
+	if req.Profile != "" {
+		if _, ok := config.FindUserByName(req.Profile); !ok {
+			c.JSON(http.StatusNotFound, gin.H{"error": config.ErrUserNotFound.Error()})
+			return
+		}
+	}
+
+	token, link, err := h.shares.Issue(expires, req.Profile, req.MaxUses)
+	if errors.Is(err, share.ErrDisabled) {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "签发分享链接失败"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"token": token,
+		"url":   baseURL(c.Request) + "/" + token,
+		"link":  link,
+	})
+}
+
+// RevokeShare 撤销分享链接，撤销后立即失效
+func (h *Handler) RevokeShare(c *gin.Context) {
+	err := h.shares.Revoke(c.Param("id"))
+	if errors.Is(err, share.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "撤销分享链接失败"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "分享链接已撤销"})
+}
@@ -0,0 +1,161 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+
+	"github.com/gin-gonic/gin"
+
+	"sublinks/config"
+	"sublinks/internal/filter"
+)
+
+// ListSources 列出所有动态订阅源，可通过 ?tag= 只列出带有该标签的订阅源
+func (h *Handler) ListSources(c *gin.Context) {
+	sources := config.GetManagedSources()
+	if tag := c.Query("tag"); tag != "" {
+		tagged := make([]config.ManagedSource, 0, len(sources))
+		for _, s := range sources {
+			if hasTag(s.Tags, tag) {
+				tagged = append(tagged, s)
+			}
+		}
+		sources = tagged
+	}
+	c.JSON(http.StatusOK, gin.H{"sources": sources})
+}
+
+// GetSource 查看指定ID的订阅源
+func (h *Handler) GetSource(c *gin.Context) {
+	source, err := config.GetManagedSource(c.Param("id"))
+	if err != nil {
+		respondSourceError(c, err, "获取订阅源失败")
+		return
+	}
+	c.JSON(http.StatusOK, source)
+}
+
+// AddSource 添加订阅源，ID 自动生成，未指定 enabled 时默认启用
+func (h *Handler) AddSource(c *gin.Context) {
+	source := config.ManagedSource{Enabled: true}
+	if err := c.BindJSON(&source); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求数据"})
+		return
+	}
+	if err := validateSource(source.Source); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	source, err := config.AddManagedSource(source)
+	if err != nil {
+		respondSourceError(c, err, "添加订阅源失败")
+		return
+	}
+	c.JSON(http.StatusCreated, source)
+}
+
+// UpdateSource 整体替换订阅源，未指定 enabled 时默认启用
+func (h *Handler) UpdateSource(c *gin.Context) {
+	source := config.ManagedSource{Enabled: true}
+	if err := c.BindJSON(&source); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求数据"})
+		return
+	}
+	h.saveSource(c, source)
+}
+
+// PatchSource 只修改请求中出现的字段，headers 整体替换
+func (h *Handler) PatchSource(c *gin.Context) {
+	source, err := config.GetManagedSource(c.Param("id"))
+	if err != nil {
+		respondSourceError(c, err, "修改订阅源失败")
+		return
+	}
+	// 解码时会写入已有的 map，先取出旧的请求头，请求中没有 headers 时再放回
+	headers := source.Headers
+	source.Headers = nil
+	if err := c.BindJSON(&source); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求数据"})
+		return
+	}
+	if source.Headers == nil {
+		source.Headers = headers
+	}
+	h.saveSource(c, source)
+}
+
+// RemoveSource 删除指定ID的订阅源
+func (h *Handler) RemoveSource(c *gin.Context) {
+	if err := config.RemoveManagedSource(c.Param("id")); err != nil {
+		respondSourceError(c, err, "删除订阅源失败")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "订阅源删除成功"})
+}
+
+// saveSource 校验并保存 PUT/PATCH 请求修改后的订阅源，ID 以路径为准
+func (h *Handler) saveSource(c *gin.Context, source config.ManagedSource) {
+	if err := validateSource(source.Source); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	source, err := config.UpdateManagedSource(c.Param("id"), source)
+	if err != nil {
+		respondSourceError(c, err, "修改订阅源失败")
+		return
+	}
+	c.JSON(http.StatusOK, source)
+}
+
+// validateSource 检查订阅地址、请求设置和过滤规则
+func validateSource(source config.Source) error {
+	if err := validateSourceURL(source.URL); err != nil {
+		return err
+	}
+	if source.Timeout < 0 || source.RefreshInterval < 0 {
+		return errors.New("timeout 和 refresh_interval 不能为负数")
+	}
+	for key := range source.Headers {
+		if key == "" {
+			return errors.New("请求头名称不能为空")
+		}
+	}
+	_, err := filter.New(source.Filter)
+	return err
+}
+
+// validateSourceURL 订阅地址必须是完整的 http 或 https 地址
+func validateSourceURL(raw string) error {
+	if raw == "" {
+		return errors.New("订阅地址不能为空")
+	}
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return errors.New("无效的订阅地址: " + raw)
+	}
+	return nil
+}
+
+func hasTag(tags []string, tag string) bool {
+	for _, t := range tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// respondSourceError 将订阅源管理的错误转换为对应的状态码
+func respondSourceError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, config.ErrSourceNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, config.ErrSourceExists):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
@@ -0,0 +1,126 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"sublinks/config"
+)
+
+// newSourcesRouter 使用临时订阅文件创建订阅源管理接口
+func newSourcesRouter(t *testing.T) *gin.Engine {
+	t.Helper()
+	saved := config.GlobalConfig
+	config.GlobalConfig = config.Config{SubscribeFile: filepath.Join(t.TempDir(), "subscribe.json")}
+	if err := config.LoadDynamicSubscribe(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		config.GlobalConfig = saved
+		config.LoadDynamicSubscribe()
+	})
+
+	gin.SetMode(gin.TestMode)
+	h := &Handler{config: &config.GlobalConfig}
+	r := gin.New()
+	r.POST("/api/sources", h.AddSource)
+	r.GET("/api/sources/:id", h.GetSource)
+	r.PUT("/api/sources/:id", h.UpdateSource)
+	r.PATCH("/api/sources/:id", h.PatchSource)
+	r.DELETE("/api/sources/:id", h.RemoveSource)
+	return r
+}
+
+func serveJSON(r http.Handler, method, path, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestSourcesAPI(t *testing.T) {
+	r := newSourcesRouter(t)
+
+	w := serveJSON(r, http.MethodPost, "/api/sources",
+		`{"name":"A","url":"https://a.example.com/sub","tags":["备用"],"priority":5,"headers":{"Authorization":"Bearer x"}}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("添加订阅源状态码 %d，期望 201: %s", w.Code, w.Body)
+	}
+	var a config.ManagedSource
+	if err := json.Unmarshal(w.Body.Bytes(), &a); err != nil {
+		t.Fatal(err)
+	}
+	if a.ID == "" || !a.Enabled {
+		t.Errorf("添加得到 %+v，期望生成ID并默认启用", a)
+	}
+	if w := serveJSON(r, http.MethodPost, "/api/sources", `{"name":"B","url":"https://b.example.com/sub"}`); w.Code != http.StatusCreated {
+		t.Fatalf("添加订阅源状态码 %d，期望 201: %s", w.Code, w.Body)
+	}
+
+	tests := []struct {
+		name         string
+		method, path string
+		body         string
+		want         int
+	}{
+		{"地址为空", http.MethodPost, "/api/sources", `{"name":"C"}`, http.StatusBadRequest},
+		{"不支持的协议", http.MethodPost, "/api/sources", `{"url":"ftp://c.example.com/sub"}`, http.StatusBadRequest},
+		{"缺少主机", http.MethodPost, "/api/sources", `{"url":"https:///sub"}`, http.StatusBadRequest},
+		{"负数超时", http.MethodPost, "/api/sources", `{"url":"https://c.example.com","timeout":-1}`, http.StatusBadRequest},
+		{"过滤规则无效", http.MethodPost, "/api/sources", `{"url":"https://c.example.com","filter":{"include":"("}}`, http.StatusBadRequest},
+		{"名称重复", http.MethodPost, "/api/sources", `{"name":"A","url":"https://c.example.com"}`, http.StatusConflict},
+		{"地址重复", http.MethodPost, "/api/sources", `{"name":"C","url":"https://a.example.com/sub"}`, http.StatusConflict},
+		{"修改为已有名称", http.MethodPatch, "/api/sources/" + a.ID, `{"name":"B"}`, http.StatusConflict},
+		{"查看不存在的ID", http.MethodGet, "/api/sources/missing", "", http.StatusNotFound},
+		{"替换不存在的ID", http.MethodPut, "/api/sources/missing", `{"url":"https://c.example.com"}`, http.StatusNotFound},
+		{"修改不存在的ID", http.MethodPatch, "/api/sources/missing", `{"enabled":false}`, http.StatusNotFound},
+		{"删除不存在的ID", http.MethodDelete, "/api/sources/missing", "", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if w := serveJSON(r, tt.method, tt.path, tt.body); w.Code != tt.want {
+				t.Errorf("状态码 %d，期望 %d: %s", w.Code, tt.want, w.Body)
+			}
+		})
+	}
+
+	// PATCH 只修改请求中出现的字段
+	w = serveJSON(r, http.MethodPatch, "/api/sources/"+a.ID, `{"enabled":false,"priority":1}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("修改订阅源状态码 %d，期望 200: %s", w.Code, w.Body)
+	}
+	want := a
+	want.Enabled = false
+	want.Priority = 1
+	got, err := config.GetManagedSource(a.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("修改后得到 %+v，期望 %+v", got, want)
+	}
+
+	// headers 出现时整体替换
+	if w := serveJSON(r, http.MethodPatch, "/api/sources/"+a.ID, `{"headers":{"X-Token":"y"}}`); w.Code != http.StatusOK {
+		t.Fatalf("修改订阅源状态码 %d，期望 200: %s", w.Code, w.Body)
+	}
+	got, _ = config.GetManagedSource(a.ID)
+	if !reflect.DeepEqual(got.Headers, map[string]string{"X-Token": "y"}) {
+		t.Errorf("修改后请求头为 %v，期望整体替换", got.Headers)
+	}
+
+	if w := serveJSON(r, http.MethodDelete, "/api/sources/"+a.ID, ""); w.Code != http.StatusOK {
+		t.Errorf("删除订阅源状态码 %d，期望 200", w.Code)
+	}
+	if w := serveJSON(r, http.MethodGet, "/api/sources/"+a.ID, ""); w.Code != http.StatusNotFound {
+		t.Errorf("查看已删除的订阅源状态码 %d，期望 404", w.Code)
+	}
+}
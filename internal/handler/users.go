@@ -0,0 +1,107 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"sublinks/config"
+	"sublinks/internal/filter"
+	"sublinks/internal/service"
+	"sublinks/internal/share"
+)
+
+// ListUsers 列出所有用户
+func (h *Handler) ListUsers(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"users": config.GetUsers()})
+}
+
+// AddUser 添加用户，未指定令牌时自动生成
+func (h *Handler) AddUser(c *gin.Context) {
+	var user config.User
+	if err := c.BindJSON(&user); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求数据"})
+		return
+	}
+	if err := validateUser(user); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, err := config.AddUser(user)
+	if err != nil {
+		respondUserError(c, err, "添加用户失败")
+		return
+	}
+	c.JSON(http.StatusCreated, user)
+}
+
+// UpdateUser 修改用户，未指定令牌时保留原令牌
+func (h *Handler) UpdateUser(c *gin.Context) {
+	var user config.User
+	if err := c.BindJSON(&user); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求数据"})
+		return
+	}
+	if user.Name == "" {
+		user.Name = c.Param("name")
+	}
+	if err := validateUser(user); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, err := config.UpdateUser(c.Param("name"), user)
+	if err != nil {
+		respondUserError(c, err, "修改用户失败")
+		return
+	}
+	c.JSON(http.StatusOK, user)
+}
+
+// RemoveUser 删除用户，其令牌立即失效
+func (h *Handler) RemoveUser(c *gin.Context) {
+	if err := config.RemoveUser(c.Param("name")); err != nil {
+		respondUserError(c, err, "删除用户失败")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "用户删除成功"})
+}
+
+// validateUser 检查用户名、令牌、默认输出格式和过滤规则，用户名和令牌不能与路由冲突
+func validateUser(user config.User) error {
+	if user.Name == "" {
+		return errors.New("用户名不能为空")
+	}
+	// 用户名出现在 /{token}/{用户名} 中，不能与输出格式或路由冲突
+	if _, ok := service.ParseTarget(user.Name); ok || isReservedPath(user.Name) || strings.Contains(user.Name, "/") {
+		return errors.New("用户名不能是输出格式、保留名称或包含 /: " + user.Name)
+	}
+	if strings.HasPrefix(user.Token, share.TokenPrefix) {
+		return errors.New("令牌不能以 " + share.TokenPrefix + " 开头")
+	}
+	if user.Token != "" && !looksLikeToken(user.Token) {
+		return errors.New("令牌不能是保留名称或包含 .")
+	}
+	if user.Target != "" {
+		if _, ok := service.ParseTarget(user.Target); !ok {
+			return errors.New("不支持的订阅格式: " + user.Target)
+		}
+	}
+	_, err := filter.New(user.Filter)
+	return err
+}
+
+// respondUserError 将用户管理的错误转换为对应的状态码
+func respondUserError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, config.ErrUserNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, config.ErrUserExists):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
@@ -0,0 +1,290 @@
+package node
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// yamlScalar 兼容字符串、数字和布尔类型的YAML字段
+type yamlScalar string
+
+func (s *yamlScalar) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind != yaml.ScalarNode {
+		return fmt.Errorf("第 %d 行: 应为单个值", value.Line)
+	}
+	*s = yamlScalar(strings.TrimSpace(value.Value))
+	return nil
+}
+
+// clashProxy Clash 配置中 proxies 的单个节点
+type clashProxy struct {
+	Name   string     `yaml:"name"`
+	Type   string     `yaml:"type"`
+	Server string     `yaml:"server"`
+	Port   yamlScalar `yaml:"port"`
+	Ports  string     `yaml:"ports"`
+
+	UUID     string     `yaml:"uuid"`
+	AlterID  yamlScalar `yaml:"alterId"`
+	Cipher   string     `yaml:"cipher"`
+	Password string     `yaml:"password"`
+	Flow     string     `yaml:"flow"`
+
+	TLS               yamlScalar `yaml:"tls"`
+	ServerName        string     `yaml:"servername"`
+	SNI               string     `yaml:"sni"`
+	ALPN              []string   `yaml:"alpn"`
+	ClientFingerprint string     `yaml:"client-fingerprint"`
+	SkipCertVerify    yamlScalar `yaml:"skip-cert-verify"`
+	RealityOpts       *struct {
+		PublicKey string `yaml:"public-key"`
+		ShortID   string `yaml:"short-id"`
+	} `yaml:"reality-opts"`
+
+	Network string `yaml:"network"`
+	WSOpts  struct {
+		Path             string            `yaml:"path"`
+		Headers          map[string]string `yaml:"headers"`
+		V2rayHTTPUpgrade yamlScalar        `yaml:"v2ray-http-upgrade"`
+	} `yaml:"ws-opts"`
+	GRPCOpts struct {
+		ServiceName string `yaml:"grpc-service-name"`
+	} `yaml:"grpc-opts"`
+	H2Opts struct {
+		Host []string `yaml:"host"`
+		Path string   `yaml:"path"`
+	} `yaml:"h2-opts"`
+	HTTPOpts struct {
+		Path    []string            `yaml:"path"`
+		Headers map[string][]string `yaml:"headers"`
+	} `yaml:"http-opts"`
+
+	Plugin     string                 `yaml:"plugin"`
+	PluginOpts map[string]interface{} `yaml:"plugin-opts"`
+
+	Protocol      string `yaml:"protocol"`
+	ProtocolParam string `yaml:"protocol-param"`
+	Obfs          string `yaml:"obfs"`
+	ObfsParam     string `yaml:"obfs-param"`
+	ObfsPassword  string `yaml:"obfs-password"`
+
+	Up                   yamlScalar `yaml:"up"`
+	Down                 yamlScalar `yaml:"down"`
+	CongestionController string     `yaml:"congestion-controller"`
+	UDPRelayMode         string     `yaml:"udp-relay-mode"`
+}
+
+// parseClash 解析 Clash 配置中的 proxies 列表，单个节点失败时不影响其他节点
+func parseClash(content string) ([]*Node, []*ParseError) {
+	var doc struct {
+		Proxies []yaml.Node `yaml:"proxies"`
+	}
+	if err := yaml.Unmarshal([]byte(content), &doc); err != nil {
+		return nil, []*ParseError{structuredError(FormatClash, "proxies", fmt.Errorf("解析Clash配置失败: %w", err))}
+	}
+
+	var nodes []*Node
+	var errs []*ParseError
+	for i := range doc.Proxies {
+		var p clashProxy
+		if err := doc.Proxies[i].Decode(&p); err != nil {
+			errs = append(errs, structuredError(FormatClash, fmt.Sprintf("第 %d 个节点", i+1), err))
+			continue
+		}
+		n, err := p.node()
+		if err != nil {
+			errs = append(errs, structuredError(p.Type, p.Name, fmt.Errorf("解析Clash节点失败: %w", err)))
+			continue
+		}
+		nodes = append(nodes, n)
+	}
+	return nodes, errs
+}
+
+// node 将 Clash 节点转换为统一的节点模型
+func (p *clashProxy) node() (*Node, error) {
+	n := &Node{
+		Name:   p.Name,
+		Server: p.Server,
+	}
+	n.Port, _ = strconv.Atoi(string(p.Port))
+
+	tls := TLS{
+		SNI:         firstNonEmpty(p.SNI, p.ServerName),
+		ALPN:        p.ALPN,
+		Fingerprint: p.ClientFingerprint,
+		Insecure:    parseBool(string(p.SkipCertVerify)),
+	}
+
+	switch strings.ToLower(p.Type) {
+	case "vmess":
+		n.Protocol = ProtocolVMess
+		n.UUID = p.UUID
+		n.Cipher = firstNonEmpty(p.Cipher, "auto")
+		n.AlterID, _ = strconv.Atoi(string(p.AlterID))
+		n.TLS = tls
+		n.TLS.Enabled = parseBool(string(p.TLS))
+		n.Transport = p.transport()
+	case "vless":
+		n.Protocol = ProtocolVLESS
+		n.UUID = p.UUID
+		n.Cipher = "none"
+		n.Flow = p.Flow
+		n.TLS = tls
+		n.TLS.Enabled = parseBool(string(p.TLS)) || p.RealityOpts != nil
+		if p.RealityOpts != nil {
+			if p.RealityOpts.PublicKey == "" {
+				return nil, errors.New("REALITY缺少公钥")
+			}
+			n.TLS.Reality = &Reality{
+				PublicKey: p.RealityOpts.PublicKey,
+				ShortID:   p.RealityOpts.ShortID,
+			}
+		}
+		n.Transport = p.transport()
+	case "trojan":
+		n.Protocol = ProtocolTrojan
+		n.Password = p.Password
+		n.TLS = tls
+		n.TLS.Enabled = true
+		n.Transport = p.transport()
+	case "ss":
+		n.Protocol = ProtocolShadowsocks
+		n.Cipher = strings.ToLower(p.Cipher)
+		n.Password = p.Password
+		if err := validateSSCipher(n.Cipher, n.Password); err != nil {
+			return nil, err
+		}
+		if err := p.setPlugin(n); err != nil {
+			return nil, err
+		}
+	case "ssr":
+		n.Protocol = ProtocolSSR
+		n.Cipher = p.Cipher
+		n.Password = p.Password
+		n.SSRProtocol = p.Protocol
+		n.SSRProtocolParam = p.ProtocolParam
+		n.Obfs = p.Obfs
+		n.ObfsParam = p.ObfsParam
+	case "hysteria2", "hy2":
+		n.Protocol = ProtocolHysteria2
+		n.Password = p.Password
+		// ports 为端口跳跃范围，未设置 port 时取范围的起始端口
+		if p.Ports != "" {
+			port, hops := resolvePorts(splitList(p.Ports))
+			if n.Port == 0 {
+				n.Port = port
+			}
+			n.Ports = hops
+		}
+		n.UpMbps = parseBandwidth(string(p.Up))
+		n.DownMbps = parseBandwidth(string(p.Down))
+		n.Obfs = p.Obfs
+		n.ObfsPassword = p.ObfsPassword
+		n.TLS = tls
+		n.TLS.Enabled = true
+	case "tuic":
+		n.Protocol = ProtocolTUIC
+		n.UUID = p.UUID
+		n.Password = p.Password
+		n.CongestionControl = p.CongestionController
+		n.UDPRelayMode = p.UDPRelayMode
+		n.TLS = tls
+		n.TLS.Enabled = true
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupported, p.Type)
+	}
+
+	if !validAddress(n) {
+		return nil, errors.New("服务器地址或端口无效")
+	}
+	return n, nil
+}
+
+// transport 读取 network 及对应的传输层选项
+func (p *clashProxy) transport() Transport {
+	switch strings.ToLower(p.Network) {
+	case "ws":
+		t := Transport{Type: "ws", Path: p.WSOpts.Path, Host: headerValue(p.WSOpts.Headers, "Host")}
+		if parseBool(string(p.WSOpts.V2rayHTTPUpgrade)) {
+			t.Type = "httpupgrade"
+		}
+		return t
+	case "grpc":
+		return Transport{Type: "grpc", ServiceName: p.GRPCOpts.ServiceName}
+	case "h2":
+		return Transport{Type: "h2", Host: strings.Join(p.H2Opts.Host, ","), Path: p.H2Opts.Path}
+	case "http":
+		t := Transport{Type: "tcp", HeaderType: "http"}
+		if len(p.HTTPOpts.Path) > 0 {
+			t.Path = p.HTTPOpts.Path[0]
+		}
+		for key, values := range p.HTTPOpts.Headers {
+			if strings.EqualFold(key, "Host") && len(values) > 0 {
+				t.Host = values[0]
+			}
+		}
+		return t
+	case "", "tcp":
+		return Transport{Type: "tcp"}
+	default:
+		return Transport{Type: strings.ToLower(p.Network)}
+	}
+}
+
+// setPlugin 将 Clash 的 plugin/plugin-opts 转换为 SIP003 插件参数
+func (p *clashProxy) setPlugin(n *Node) error {
+	opt := func(key string) string {
+		if v, ok := p.PluginOpts[key]; ok && v != nil {
+			return fmt.Sprint(v)
+		}
+		return ""
+	}
+
+	var opts []string
+	switch p.Plugin {
+	case "":
+		return nil
+	case "obfs":
+		n.Plugin = "obfs-local"
+		opts = append(opts, "obfs="+opt("mode"))
+		if host := opt("host"); host != "" {
+			opts = append(opts, "obfs-host="+host)
+		}
+	case "v2ray-plugin":
+		n.Plugin = "v2ray-plugin"
+		if mode := opt("mode"); mode != "" {
+			opts = append(opts, "mode="+mode)
+		}
+		if parseBool(opt("tls")) {
+			opts = append(opts, "tls")
+		}
+		if host := opt("host"); host != "" {
+			opts = append(opts, "host="+host)
+		}
+		if path := opt("path"); path != "" {
+			opts = append(opts, "path="+path)
+		}
+		if parseBool(opt("mux")) {
+			opts = append(opts, "mux")
+		}
+	default:
+		return errors.New("不支持的插件: " + p.Plugin)
+	}
+	n.PluginOpts = strings.Join(opts, ";")
+	return nil
+}
+
+// headerValue 不区分大小写地读取请求头
+func headerValue(headers map[string]string, key string) string {
+	for k, v := range headers {
+		if strings.EqualFold(k, key) {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,74 @@
+package node
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+)
+
+// 订阅内容格式
+const (
+	FormatURIList = "uri"
+	FormatClash   = "clash"
+	FormatSingBox = "singbox"
+	FormatSIP008  = "sip008"
+)
+
+// clashProxiesPattern 匹配 Clash 配置中顶层的 proxies 字段
+var clashProxiesPattern = regexp.MustCompile(`(?m)^proxies\s*:`)
+
+// DetectFormat 识别订阅内容格式，无法识别为结构化格式时视为（可能经过base64编码的）链接列表
+func DetectFormat(content string) string {
+	trimmed := strings.TrimSpace(strings.TrimPrefix(content, "\ufeff"))
+	if strings.HasPrefix(trimmed, "{") {
+		var probe struct {
+			Outbounds json.RawMessage `json:"outbounds"`
+			Servers   json.RawMessage `json:"servers"`
+		}
+		if json.Unmarshal([]byte(trimmed), &probe) == nil {
+			switch {
+			case probe.Outbounds != nil:
+				return FormatSingBox
+			case probe.Servers != nil:
+				return FormatSIP008
+			}
+		}
+	}
+	if clashProxiesPattern.MatchString(trimmed) {
+		return FormatClash
+	}
+	return FormatURIList
+}
+
+// ParseContent 自动识别订阅内容格式并解析节点，支持 Clash YAML、sing-box JSON、SIP008 JSON
+// 以及base64编码或明文的分享链接列表
+func ParseContent(content, source string) ([]*Node, []*ParseError) {
+	var nodes []*Node
+	var errs []*ParseError
+
+	content = strings.TrimPrefix(content, "\ufeff")
+	switch DetectFormat(content) {
+	case FormatClash:
+		nodes, errs = parseClash(content)
+	case FormatSingBox:
+		nodes, errs = parseSingBox(content)
+	case FormatSIP008:
+		nodes, errs = parseSIP008(content)
+	default:
+		// 尝试base64解码
+		if decoded, err := DecodeBase64(content); err == nil {
+			content = string(decoded)
+		}
+		return ParseLines(content, source)
+	}
+
+	for _, n := range nodes {
+		n.Source = source
+	}
+	return nodes, errs
+}
+
+// structuredError 生成结构化格式中单个节点的解析错误
+func structuredError(typ, name string, err error) *ParseError {
+	return &ParseError{Line: typ + ": " + name, Err: err, Structured: true}
+}
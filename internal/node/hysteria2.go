@@ -0,0 +1,157 @@
+package node
+
+import (
+	"errors"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+func init() {
+	Register(ProtocolHysteria2, []string{"hysteria2", "hy2"}, parseHysteria2, formatHysteria2)
+}
+
+// parseHysteria2 解析 hysteria2://auth@host:port[,ports]/?obfs=salamander&sni=...#name 格式
+func parseHysteria2(uri string) (*Node, error) {
+	body := uri[strings.Index(uri, "://")+3:]
+	payload, rawQuery, fragment := splitURIBody(body)
+
+	n := &Node{Protocol: ProtocolHysteria2, Name: fragment}
+	hostPort := payload
+	if at := strings.LastIndex(payload, "@"); at >= 0 {
+		n.Password, _ = url.PathUnescape(payload[:at])
+		hostPort = payload[at+1:]
+	}
+
+	// 端口部分可能包含跳跃范围，如 host:443,20000-30000
+	host, ports, err := splitHostPorts(hostPort)
+	if err != nil {
+		return nil, err
+	}
+	n.Server = host
+
+	q, _ := url.ParseQuery(rawQuery)
+	if mport := q.Get("mport"); mport != "" {
+		ports = append(ports, mport)
+	}
+	n.Port, n.Ports = resolvePorts(ports)
+	if n.Port == 0 {
+		return nil, errors.New("端口无效")
+	}
+
+	if n.Password == "" {
+		n.Password = q.Get("auth")
+	}
+	if n.Password == "" {
+		return nil, errors.New("缺少密码")
+	}
+	n.Obfs = q.Get("obfs")
+	n.ObfsPassword = q.Get("obfs-password")
+	n.UpMbps = parseBandwidth(firstNonEmpty(q.Get("up"), q.Get("upmbps")))
+	n.DownMbps = parseBandwidth(firstNonEmpty(q.Get("down"), q.Get("downmbps")))
+
+	n.TLS = TLS{
+		Enabled:  true,
+		SNI:      firstNonEmpty(q.Get("sni"), q.Get("peer")),
+		Insecure: parseBool(q.Get("insecure")) || parseBool(q.Get("allowInsecure")),
+	}
+	if alpn := q.Get("alpn"); alpn != "" {
+		n.TLS.ALPN = splitList(alpn)
+	}
+	return n, nil
+}
+
+// formatHysteria2 编码为 hysteria2:// 链接
+func formatHysteria2(n *Node) string {
+	q := url.Values{}
+	if n.Obfs != "" {
+		q.Set("obfs", n.Obfs)
+		q.Set("obfs-password", n.ObfsPassword)
+	}
+	if n.TLS.SNI != "" {
+		q.Set("sni", n.TLS.SNI)
+	}
+	if n.TLS.Insecure {
+		q.Set("insecure", "1")
+	}
+	if len(n.TLS.ALPN) > 0 {
+		q.Set("alpn", strings.Join(n.TLS.ALPN, ","))
+	}
+	if n.Ports != "" {
+		q.Set("mport", n.Ports)
+	}
+	if n.UpMbps > 0 {
+		q.Set("up", strconv.Itoa(n.UpMbps))
+	}
+	if n.DownMbps > 0 {
+		q.Set("down", strconv.Itoa(n.DownMbps))
+	}
+
+	u := url.URL{
+		Scheme:   "hysteria2",
+		User:     url.User(n.Password),
+		Host:     n.Address(),
+		Path:     "/",
+		RawQuery: q.Encode(),
+		Fragment: n.Name,
+	}
+	return u.String()
+}
+
+// splitHostPorts 拆分主机与逗号分隔的端口列表，跳跃范围可能以 : 连接，如 host:443,20000:30000
+func splitHostPorts(hostPort string) (string, []string, error) {
+	hostPort = strings.TrimSuffix(hostPort, "/")
+	var host, ports string
+	if strings.HasPrefix(hostPort, "[") {
+		end := strings.Index(hostPort, "]")
+		if end < 0 || !strings.HasPrefix(hostPort[end+1:], ":") {
+			return "", nil, errors.New("缺少端口")
+		}
+		host, ports = hostPort[1:end], hostPort[end+2:]
+	} else {
+		idx := strings.Index(hostPort, ":")
+		if idx < 0 {
+			return "", nil, errors.New("缺少端口")
+		}
+		host, ports = hostPort[:idx], hostPort[idx+1:]
+	}
+
+	if host == "" {
+		return "", nil, errors.New("服务器地址无效")
+	}
+	return host, splitList(ports), nil
+}
+
+// resolvePorts 从端口列表中取出主端口与跳跃范围，范围统一以 - 连接
+func resolvePorts(ports []string) (int, string) {
+	var port int
+	var hops []string
+	for _, p := range ports {
+		p = strings.ReplaceAll(p, ":", "-")
+		if strings.Contains(p, "-") {
+			hops = append(hops, p)
+			continue
+		}
+		if v, err := strconv.Atoi(p); err == nil && port == 0 {
+			port = v
+		}
+	}
+	if port == 0 && len(hops) > 0 {
+		port, _ = strconv.Atoi(strings.SplitN(hops[0], "-", 2)[0])
+	}
+	return port, strings.Join(hops, ",")
+}
+
+// parseBandwidth 解析带宽参数，支持 "100"、"100 Mbps" 等写法，单位为Mbps
+func parseBandwidth(s string) int {
+	s = strings.TrimSpace(strings.ToLower(s))
+	end := 0
+	for end < len(s) && s[end] >= '0' && s[end] <= '9' {
+		end++
+	}
+	v, _ := strconv.Atoi(s[:end])
+	if strings.Contains(s[end:], "g") {
+		v *= 1000
+	}
+	return v
+}
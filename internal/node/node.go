@@ -0,0 +1,184 @@
+package node
+
+import (
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Protocol 节点协议类型
+type Protocol string
+
+const (
+	ProtocolVMess       Protocol = "vmess"
+	ProtocolShadowsocks Protocol = "ss"
+	ProtocolSSR         Protocol = "ssr"
+	ProtocolTrojan      Protocol = "trojan"
+	ProtocolVLESS       Protocol = "vless"
+	ProtocolHysteria2   Protocol = "hysteria2"
+	ProtocolTUIC        Protocol = "tuic"
+
+	// ProtocolUnknown 无法解析但需要原样透传的节点
+	ProtocolUnknown Protocol = "unknown"
+)
+
+// Transport 传输层配置
+type Transport struct {
+	Type        string // tcp/ws/grpc/h2/httpupgrade
+	Host        string
+	Path        string
+	ServiceName string
+	HeaderType  string
+}
+
+// network 返回传输方式，未指定和 raw 均视为 tcp
+func (t Transport) network() string {
+	switch network := strings.ToLower(t.Type); network {
+	case "", "raw":
+		return "tcp"
+	default:
+		return network
+	}
+}
+
+// Reality REALITY 握手参数
+type Reality struct {
+	PublicKey string
+	ShortID   string
+	SpiderX   string
+}
+
+// TLS 传输安全配置，Reality 非空时表示使用 REALITY
+type TLS struct {
+	Enabled     bool
+	SNI         string
+	ALPN        []string
+	Fingerprint string
+	Insecure    bool
+	Reality     *Reality
+}
+
+// Node 统一的节点模型，由解析器生成并供各输出格式使用
+type Node struct {
+	Protocol Protocol
+	Name     string
+	Server   string
+	Port     int
+
+	// 凭据
+	UUID     string
+	Password string
+	Cipher   string
+	AlterID  int
+	Flow     string
+
+	Transport Transport
+	TLS       TLS
+
+	// Shadowsocks 插件，PluginOpts 为原始的 SIP003 参数串
+	Plugin     string
+	PluginOpts string
+
+	// ShadowsocksR 协议与混淆参数，混淆方式复用 Obfs 字段
+	SSRProtocol      string
+	SSRProtocolParam string
+	ObfsParam        string
+
+	// QUIC 类协议参数（Hysteria2/TUIC）
+	Obfs              string
+	ObfsPassword      string
+	UpMbps            int
+	DownMbps          int
+	Ports             string // 端口跳跃范围，如 20000-30000
+	CongestionControl string
+	UDPRelayMode      string
+
+	// Source 节点来源（订阅地址或主数据）
+	Source string
+
+	// Raw 原始链接，仅 ProtocolUnknown 节点使用
+	Raw string
+}
+
+// SourceMain 主数据（main_data）中节点的来源名称
+const SourceMain = "main"
+
+// NewUnknown 创建原样透传的节点
+func NewUnknown(line, source string) *Node {
+	return &Node{Protocol: ProtocolUnknown, Raw: line, Source: source}
+}
+
+// Address 返回 host:port 形式的地址
+func (n *Node) Address() string {
+	return net.JoinHostPort(n.Server, strconv.Itoa(n.Port))
+}
+
+// Clone 复制节点，避免修改共享数据
+func (n *Node) Clone() *Node {
+	c := *n
+	if n.TLS.ALPN != nil {
+		c.TLS.ALPN = append([]string(nil), n.TLS.ALPN...)
+	}
+	if n.TLS.Reality != nil {
+		reality := *n.TLS.Reality
+		c.TLS.Reality = &reality
+	}
+	return &c
+}
+
+// Key 返回节点的身份标识：协议、服务器、端口、凭据、传输方式、插件和混淆方式均相同的节点视为同一节点，
+// 与名称、参数顺序和编码方式无关
+func (n *Node) Key() string {
+	if n.Protocol == ProtocolUnknown {
+		return "raw|" + strings.TrimSpace(n.Raw)
+	}
+	return strings.Join([]string{
+		string(n.Protocol),
+		strings.ToLower(n.Server),
+		strconv.Itoa(n.Port),
+		strings.ToLower(n.UUID),
+		n.Password,
+		n.Cipher,
+		n.Transport.network(),
+		strings.ToLower(n.Transport.Host),
+		n.Transport.Path,
+		n.Transport.ServiceName,
+		n.pluginKey(),
+		n.pluginOptsKey(),
+		n.SSRProtocol,
+		n.Obfs,
+	}, "|")
+}
+
+// pluginKey 返回插件名称，simple-obfs 与 obfs-local 为同一插件
+func (n *Node) pluginKey() string {
+	if n.Plugin == "simple-obfs" {
+		return "obfs-local"
+	}
+	return n.Plugin
+}
+
+// pluginOptsKey 返回按选项排序后的插件参数，参数顺序不同的节点视为同一节点
+func (n *Node) pluginOptsKey() string {
+	var opts []string
+	for _, item := range strings.Split(n.PluginOpts, ";") {
+		if item = strings.TrimSpace(item); item != "" {
+			opts = append(opts, item)
+		}
+	}
+	sort.Strings(opts)
+	return strings.Join(opts, ";")
+}
+
+// URI 将节点重新编码为分享链接
+func (n *Node) URI() string {
+	if n.Protocol == ProtocolUnknown {
+		return n.Raw
+	}
+	format, ok := formatters[n.Protocol]
+	if !ok {
+		return ""
+	}
+	return format(n)
+}
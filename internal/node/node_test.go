@@ -0,0 +1,195 @@
+package node
+
+import (
+	"encoding/base64"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+// parseCase 分享链接及期望解析出的节点
+type parseCase struct {
+	name string
+	uri  string
+	want Node
+}
+
+// testParse 逐条解析分享链接并与期望的节点比较
+func testParse(t *testing.T, tests []parseCase) {
+	t.Helper()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n, err := Parse(tt.uri)
+			if err != nil {
+				t.Fatalf("Parse(%q) 失败: %v", tt.uri, err)
+			}
+			if !reflect.DeepEqual(*n, tt.want) {
+				t.Errorf("Parse(%q)\n得到 %+v\n期望 %+v", tt.uri, *n, tt.want)
+			}
+		})
+	}
+}
+
+// testParseInvalid 检查无效的分享链接均解析失败
+func testParseInvalid(t *testing.T, uris []string) {
+	t.Helper()
+	for _, uri := range uris {
+		if n, err := Parse(uri); err == nil {
+			t.Errorf("Parse(%q) 应失败，得到 %+v", uri, *n)
+		}
+	}
+}
+
+func TestParseVLESS(t *testing.T) {
+	testParse(t, []parseCase{
+		{
+			name: "REALITY",
+			uri: "vless://b831381d-6324-4d53-ad4f-8cda48b30811@1.2.3.4:443?encryption=none&flow=xtls-rprx-vision&security=reality" +
+				"&sni=www.microsoft.com&fp=chrome&pbk=7xhH4b_VkliBxGulljcyPOH-bYUA2dl-XAdZAsfhk04&sid=6ba85179e30d4fc2&spx=%2Fpath&type=tcp&headerType=none#JP%20Reality",
+			want: Node{
+				Protocol: ProtocolVLESS, Name: "JP Reality", Server: "1.2.3.4", Port: 443,
+				UUID: "b831381d-6324-4d53-ad4f-8cda48b30811", Cipher: "none", Flow: "xtls-rprx-vision",
+				Transport: Transport{Type: "tcp"},
+				TLS: TLS{
+					Enabled: true, SNI: "www.microsoft.com", Fingerprint: "chrome",
+					Reality: &Reality{PublicKey: "7xhH4b_VkliBxGulljcyPOH-bYUA2dl-XAdZAsfhk04", ShortID: "6ba85179e30d4fc2", SpiderX: "/path"},
+				},
+			},
+		},
+		{
+			name: "REALITY grpc",
+			uri:  "vless://uuid-1@grpc.example.com:443?security=reality&pbk=key&sid=01&fp=firefox&type=grpc&serviceName=gsvc&mode=multi#gRPC",
+			want: Node{
+				Protocol: ProtocolVLESS, Name: "gRPC", Server: "grpc.example.com", Port: 443,
+				UUID: "uuid-1", Cipher: "none",
+				Transport: Transport{Type: "grpc", ServiceName: "gsvc", HeaderType: "multi"},
+				TLS:       TLS{Enabled: true, Fingerprint: "firefox", Reality: &Reality{PublicKey: "key", ShortID: "01"}},
+			},
+		},
+		{
+			name: "ws tls",
+			uri:  "vless://uuid-2@ws.example.com:8443?security=tls&type=ws&host=cdn.example.com&path=%2Fray%3Fed%3D2048&sni=ws.example.com&alpn=h2,http/1.1&allowInsecure=1#WS",
+			want: Node{
+				Protocol: ProtocolVLESS, Name: "WS", Server: "ws.example.com", Port: 8443,
+				UUID: "uuid-2", Cipher: "none",
+				Transport: Transport{Type: "ws", Host: "cdn.example.com", Path: "/ray?ed=2048"},
+				TLS:       TLS{Enabled: true, SNI: "ws.example.com", ALPN: []string{"h2", "http/1.1"}, Insecure: true},
+			},
+		},
+		{
+			name: "raw 视为 tcp",
+			uri:  "vless://uuid-3@[2001:db8::1]:80?type=raw&security=none#v6",
+			want: Node{
+				Protocol: ProtocolVLESS, Name: "v6", Server: "2001:db8::1", Port: 80,
+				UUID: "uuid-3", Cipher: "none",
+				Transport: Transport{Type: "tcp"},
+			},
+		},
+	})
+}
+
+func TestParseVLESSInvalid(t *testing.T) {
+	testParseInvalid(t, []string{
+		"vless://uuid@example.com:443?security=reality&sid=01",
+		"vless://uuid@example.com:443?security=reality&pbk=",
+		"vless://@example.com:443?security=tls",
+		"vless://example.com:443",
+		"vless://uuid@example.com:abc",
+		"vless://uuid@example.com",
+		"vless://uuid@example.com:443?security=unknown",
+	})
+}
+
+func TestKeySameNodeAcrossFormats(t *testing.T) {
+	tests := []struct {
+		name  string
+		lines []string
+		clash string
+		box   string
+	}{
+		{
+			name: "trojan",
+			lines: []string{
+				"trojan://secret@Example.com:443?sni=example.com#A",
+				"trojan://secret@example.com:443?type=tcp&security=tls#B",
+			},
+			clash: `proxies:
+  - {name: C, type: trojan, server: example.com, port: 443, password: secret, network: tcp}
+  - {name: D, type: trojan, server: example.com, port: 443, password: secret}
+`,
+			box: `{"outbounds":[{"type":"trojan","tag":"E","server":"example.com","server_port":443,"password":"secret","tls":{"enabled":true}}]}`,
+		},
+		{
+			name: "vless",
+			lines: []string{
+				"vless://11111111-2222-3333-4444-555555555555@example.com:443?security=tls#A",
+				"vless://11111111-2222-3333-4444-555555555555@example.com:443?type=raw&security=tls#B",
+			},
+			clash: `proxies:
+  - {name: C, type: vless, server: example.com, port: 443, uuid: 11111111-2222-3333-4444-555555555555, tls: true}
+`,
+			box: `{"outbounds":[{"type":"vless","tag":"E","server":"example.com","server_port":443,"uuid":"11111111-2222-3333-4444-555555555555","tls":{"enabled":true}}]}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var nodes []*Node
+			for _, content := range append(tt.lines, tt.clash, tt.box) {
+				parsed, errs := ParseContent(content, "test")
+				if len(errs) > 0 {
+					t.Fatalf("解析 %q 失败: %v", content, errs[0])
+				}
+				nodes = append(nodes, parsed...)
+			}
+			want := nodes[0].Key()
+			for _, n := range nodes[1:] {
+				if got := n.Key(); got != want {
+					t.Errorf("%s 得到 %q，期望 %q", n.Name, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestKeyDistinguishesEndpoints(t *testing.T) {
+	ssr := func(protocol, obfs string) string {
+		return "ssr://" + base64.RawURLEncoding.EncodeToString([]byte("ssr.example.com:443:"+protocol+":aes-256-cfb:"+obfs+":"+
+			base64.RawURLEncoding.EncodeToString([]byte("secret"))))
+	}
+	ss := func(plugin string) string {
+		uri := "ss://" + base64.RawURLEncoding.EncodeToString([]byte("aes-256-gcm:secret")) + "@ss.example.com:8388"
+		if plugin != "" {
+			uri += "/?plugin=" + url.QueryEscape(plugin)
+		}
+		return uri
+	}
+
+	tests := []struct {
+		name string
+		a, b string
+		same bool
+	}{
+		{"SSR 协议", ssr("origin", "plain"), ssr("auth_aes128_md5", "plain"), false},
+		{"SSR 混淆", ssr("origin", "plain"), ssr("origin", "http_simple"), false},
+		{"SS 插件", ss(""), ss("obfs-local;obfs=http"), false},
+		{"SS 插件参数", ss("obfs-local;obfs=http"), ss("obfs-local;obfs=tls"), false},
+		{"SS 插件参数顺序", ss("obfs-local;obfs=http;obfs-host=a.com"), ss("simple-obfs;obfs-host=a.com;obfs=http"), true},
+		{"Hysteria2 混淆", "hy2://secret@hy.example.com:443", "hy2://secret@hy.example.com:443?obfs=salamander&obfs-password=x", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, err := Parse(tt.a)
+			if err != nil {
+				t.Fatal(err)
+			}
+			b, err := Parse(tt.b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if same := a.Key() == b.Key(); same != tt.same {
+				t.Errorf("%q 与 %q 标识相同为 %v，期望 %v", a.Key(), b.Key(), same, tt.same)
+			}
+		})
+	}
+}
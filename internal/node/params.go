@@ -0,0 +1,88 @@
+package node
+
+import (
+	"net/url"
+	"strings"
+)
+
+// parseTransportQuery 读取链接参数中的传输层配置
+func parseTransportQuery(n *Node, q url.Values) {
+	n.Transport.Type = q.Get("type")
+	n.Transport.Host = q.Get("host")
+	n.Transport.Path = q.Get("path")
+	n.Transport.ServiceName = q.Get("serviceName")
+	if headerType := q.Get("headerType"); headerType != "none" {
+		n.Transport.HeaderType = headerType
+	}
+}
+
+// setTransportQuery 写入传输层配置到链接参数
+func setTransportQuery(q url.Values, t Transport) {
+	if t.Type != "" && t.Type != "tcp" {
+		q.Set("type", t.Type)
+	}
+	if t.Host != "" {
+		q.Set("host", t.Host)
+	}
+	if t.Path != "" {
+		q.Set("path", t.Path)
+	}
+	if t.ServiceName != "" {
+		q.Set("serviceName", t.ServiceName)
+	}
+	if t.HeaderType != "" && t.HeaderType != "none" {
+		q.Set("headerType", t.HeaderType)
+	}
+}
+
+// setTLSQuery 写入ALPN与指纹参数
+func setTLSQuery(q url.Values, t TLS) {
+	if len(t.ALPN) > 0 {
+		q.Set("alpn", strings.Join(t.ALPN, ","))
+	}
+	if t.Fingerprint != "" {
+		q.Set("fp", t.Fingerprint)
+	}
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// splitURIBody 将去掉协议前缀的链接拆分为主体、查询参数和名称
+func splitURIBody(body string) (payload, rawQuery, fragment string) {
+	if idx := strings.Index(body, "#"); idx >= 0 {
+		fragment, _ = url.PathUnescape(body[idx+1:])
+		body = body[:idx]
+	}
+	if idx := strings.Index(body, "?"); idx >= 0 {
+		rawQuery = body[idx+1:]
+		body = body[:idx]
+	}
+	return strings.TrimSuffix(body, "/"), rawQuery, fragment
+}
+
+// parseBool 解析链接参数中的布尔值
+func parseBool(s string) bool {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "1", "true", "yes", "on":
+		return true
+	}
+	return false
+}
+
+// splitList 拆分逗号分隔的列表并去除空项
+func splitList(s string) []string {
+	var result []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			result = append(result, item)
+		}
+	}
+	return result
+}
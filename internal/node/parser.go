@@ -0,0 +1,126 @@
+package node
+
+import (
+	"bufio"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrUnsupported 表示节点协议没有注册解析器
+var ErrUnsupported = errors.New("不支持的节点协议")
+
+// ParseError 记录无法解析的行及原因
+type ParseError struct {
+	Line string
+	Err  error
+	// Structured 错误来自 Clash/sing-box 等结构化格式，Line 只是节点描述，不能作为链接透传
+	Structured bool
+}
+
+func (e *ParseError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// ParseFunc 将分享链接解析为节点
+type ParseFunc func(uri string) (*Node, error)
+
+// FormatFunc 将节点编码为分享链接
+type FormatFunc func(n *Node) string
+
+var (
+	parsers    = make(map[string]ParseFunc)
+	formatters = make(map[Protocol]FormatFunc)
+)
+
+// Register 注册协议的解析器和编码器，schemes 为该协议可用的链接前缀
+func Register(protocol Protocol, schemes []string, parse ParseFunc, format FormatFunc) {
+	for _, scheme := range schemes {
+		parsers[scheme] = parse
+	}
+	formatters[protocol] = format
+}
+
+// Parse 解析单条分享链接
+func Parse(line string) (*Node, error) {
+	line = strings.TrimSpace(line)
+	idx := strings.Index(line, "://")
+	if idx <= 0 {
+		return nil, fmt.Errorf("%w: 无法识别的链接", ErrUnsupported)
+	}
+
+	scheme := strings.ToLower(line[:idx])
+	parse, ok := parsers[scheme]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupported, scheme)
+	}
+
+	n, err := parse(line)
+	if err != nil {
+		return nil, fmt.Errorf("解析%s节点失败: %w", scheme, err)
+	}
+	if !validAddress(n) {
+		return nil, fmt.Errorf("解析%s节点失败: 服务器地址或端口无效", scheme)
+	}
+	return n, nil
+}
+
+// validAddress 检查节点的服务器地址和端口
+func validAddress(n *Node) bool {
+	return n.Server != "" && n.Port > 0 && n.Port <= 65535
+}
+
+// ParseLines 解析换行分隔的节点列表，返回成功解析的节点和失败的行
+func ParseLines(content, source string) ([]*Node, []*ParseError) {
+	var nodes []*Node
+	var errs []*ParseError
+
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		n, err := Parse(line)
+		if err != nil {
+			errs = append(errs, &ParseError{Line: line, Err: err})
+			continue
+		}
+		n.Source = source
+		nodes = append(nodes, n)
+	}
+	return nodes, errs
+}
+
+// DecodeBase64 依次尝试标准、URL安全及无填充格式解码base64
+func DecodeBase64(s string) ([]byte, error) {
+	s = strings.Map(func(r rune) rune {
+		if r == '\r' || r == '\n' || r == ' ' || r == '\t' {
+			return -1
+		}
+		return r
+	}, s)
+
+	encodings := []*base64.Encoding{
+		base64.StdEncoding,
+		base64.URLEncoding,
+	}
+	for _, enc := range encodings {
+		if data, err := enc.DecodeString(s); err == nil {
+			return data, nil
+		}
+	}
+
+	trimmed := strings.TrimRight(s, "=")
+	if data, err := base64.RawStdEncoding.DecodeString(trimmed); err == nil {
+		return data, nil
+	}
+	return base64.RawURLEncoding.DecodeString(trimmed)
+}
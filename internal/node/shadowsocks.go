@@ -0,0 +1,151 @@
+package node
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+func init() {
+	Register(ProtocolShadowsocks, []string{"ss"}, parseShadowsocks, formatShadowsocks)
+}
+
+// ssCiphers 支持的Shadowsocks加密方式
+var ssCiphers = map[string]struct{}{
+	"none": {}, "plain": {},
+	"aes-128-gcm": {}, "aes-192-gcm": {}, "aes-256-gcm": {},
+	"chacha20-ietf-poly1305": {}, "xchacha20-ietf-poly1305": {},
+	"aes-128-cfb": {}, "aes-192-cfb": {}, "aes-256-cfb": {},
+	"aes-128-ctr": {}, "aes-192-ctr": {}, "aes-256-ctr": {},
+	"rc4-md5": {}, "chacha20": {}, "chacha20-ietf": {}, "xchacha20": {},
+	"2022-blake3-aes-128-gcm": {}, "2022-blake3-aes-256-gcm": {},
+	"2022-blake3-chacha20-poly1305": {},
+}
+
+// parseShadowsocks 解析 SIP002 格式 ss://userinfo@host:port/?plugin=...#name，
+// 以及旧版 ss://base64(method:password@host:port)#name 格式
+func parseShadowsocks(uri string) (*Node, error) {
+	body := uri[strings.Index(uri, "://")+3:]
+	payload, rawQuery, fragment := splitURIBody(body)
+
+	// 旧版格式整体经过base64编码
+	if !strings.Contains(payload, "@") {
+		decoded, err := DecodeBase64(payload)
+		if err != nil {
+			return nil, err
+		}
+		payload = string(decoded)
+	}
+
+	at := strings.LastIndex(payload, "@")
+	if at < 0 {
+		return nil, errors.New("缺少服务器地址")
+	}
+
+	n := &Node{Protocol: ProtocolShadowsocks, Name: fragment}
+	method, password, err := parseSSUserInfo(payload[:at])
+	if err != nil {
+		return nil, err
+	}
+	n.Cipher, n.Password = method, password
+	if err := validateSSCipher(n.Cipher, n.Password); err != nil {
+		return nil, err
+	}
+
+	u, err := url.Parse("ss://" + payload[at+1:])
+	if err != nil {
+		return nil, err
+	}
+	n.Server = u.Hostname()
+	if n.Port, err = strconv.Atoi(u.Port()); err != nil {
+		return nil, errors.New("端口无效")
+	}
+
+	q, _ := url.ParseQuery(rawQuery)
+	if plugin := q.Get("plugin"); plugin != "" {
+		// 插件参数格式为 name;opt1=v1;opt2
+		parts := strings.SplitN(plugin, ";", 2)
+		n.Plugin = parts[0]
+		if len(parts) == 2 {
+			n.PluginOpts = parts[1]
+		}
+	}
+	return n, nil
+}
+
+// parseSSUserInfo 解析用户信息，兼容base64编码和 SIP022 的明文编码
+func parseSSUserInfo(userInfo string) (string, string, error) {
+	if decoded, err := DecodeBase64(userInfo); err == nil && strings.Contains(string(decoded), ":") {
+		userInfo = string(decoded)
+	} else if unescaped, err := url.PathUnescape(userInfo); err == nil {
+		userInfo = unescaped
+	}
+
+	parts := strings.SplitN(userInfo, ":", 2)
+	if len(parts) != 2 || parts[0] == "" {
+		return "", "", errors.New("加密方式或密码无效")
+	}
+	return strings.ToLower(parts[0]), parts[1], nil
+}
+
+// validateSSCipher 校验加密方式，2022 系列要求密钥为对应长度的base64
+func validateSSCipher(method, password string) error {
+	if _, ok := ssCiphers[method]; !ok {
+		return errors.New("不支持的加密方式: " + method)
+	}
+	if !strings.HasPrefix(method, "2022-") {
+		return nil
+	}
+
+	keyLen := 32
+	if method == "2022-blake3-aes-128-gcm" {
+		keyLen = 16
+	}
+	// 多用户模式下密码为 iPSK:uPSK
+	for _, key := range strings.Split(password, ":") {
+		data, err := base64.StdEncoding.DecodeString(key)
+		if err != nil || len(data) != keyLen {
+			return errors.New("2022密钥长度无效")
+		}
+	}
+	return nil
+}
+
+// formatShadowsocks 编码为 SIP002 格式，2022 系列使用明文用户信息
+func formatShadowsocks(n *Node) string {
+	var userInfo string
+	if strings.HasPrefix(n.Cipher, "2022-") {
+		userInfo = url.PathEscape(n.Cipher) + ":" + url.PathEscape(n.Password)
+	} else {
+		userInfo = base64.RawURLEncoding.EncodeToString([]byte(n.Cipher + ":" + n.Password))
+	}
+
+	uri := "ss://" + userInfo + "@" + n.Address()
+	if n.Plugin != "" {
+		plugin := n.Plugin
+		if n.PluginOpts != "" {
+			plugin += ";" + n.PluginOpts
+		}
+		uri += "/?plugin=" + url.QueryEscape(plugin)
+	}
+	return uri + "#" + url.PathEscape(n.Name)
+}
+
+// PluginOptions 将 SIP003 插件参数解析为键值对，无值的选项记为 "true"
+func (n *Node) PluginOptions() map[string]string {
+	opts := make(map[string]string)
+	for _, item := range strings.Split(n.PluginOpts, ";") {
+		if item = strings.TrimSpace(item); item == "" {
+			continue
+		}
+		kv := strings.SplitN(item, "=", 2)
+		if len(kv) == 2 {
+			opts[kv[0]] = kv[1]
+		} else {
+			opts[kv[0]] = "true"
+		}
+	}
+	return opts
+}
@@ -0,0 +1,179 @@
+package node
+
+import (
+	"encoding/base64"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+// 2022 系列的测试密钥，包含 base64 中的 "+" 和 "/"
+const (
+	ssKey16 = "+//7//v/+//7//v/+//7/w=="
+	ssKey32 = "++/77/vv++/77/vv++/77/vv++/77/vv++/77/vv++8="
+	ssPSK32 = "AAECAwQFBgcICQoLDA0ODxAREhMUFRYXGBkaGxwdHh8="
+)
+
+func TestParseShadowsocks(t *testing.T) {
+	std := func(s string) string { return base64.StdEncoding.EncodeToString([]byte(s)) }
+	rawURL := func(s string) string { return base64.RawURLEncoding.EncodeToString([]byte(s)) }
+
+	testParse(t, []parseCase{
+		{
+			name: "SIP002 obfs-local",
+			uri:  "ss://" + rawURL("aes-256-gcm:pass") + "@ss.example.com:8388/?plugin=" + url.QueryEscape("obfs-local;obfs=http;obfs-host=cdn.example.com") + "#SS%2001",
+			want: Node{
+				Protocol: ProtocolShadowsocks, Name: "SS 01", Server: "ss.example.com", Port: 8388,
+				Cipher: "aes-256-gcm", Password: "pass",
+				Plugin: "obfs-local", PluginOpts: "obfs=http;obfs-host=cdn.example.com",
+			},
+		},
+		{
+			name: "SIP002 v2ray-plugin",
+			uri:  "ss://" + std("chacha20-ietf-poly1305:pass") + "@[2001:db8::1]:443?plugin=" + url.QueryEscape("v2ray-plugin;tls;mode=websocket;host=ws.example.com;path=/ws") + "#v6",
+			want: Node{
+				Protocol: ProtocolShadowsocks, Name: "v6", Server: "2001:db8::1", Port: 443,
+				Cipher: "chacha20-ietf-poly1305", Password: "pass",
+				Plugin: "v2ray-plugin", PluginOpts: "tls;mode=websocket;host=ws.example.com;path=/ws",
+			},
+		},
+		{
+			name: "密码包含冒号",
+			uri:  "ss://" + std("AES-128-GCM:a:b@c") + "@ss.example.com:8388#colon",
+			want: Node{
+				Protocol: ProtocolShadowsocks, Name: "colon", Server: "ss.example.com", Port: 8388,
+				Cipher: "aes-128-gcm", Password: "a:b@c",
+			},
+		},
+		{
+			name: "明文用户信息",
+			uri:  "ss://aes-256-gcm:p%3As%40s@ss.example.com:8388#plain",
+			want: Node{
+				Protocol: ProtocolShadowsocks, Name: "plain", Server: "ss.example.com", Port: 8388,
+				Cipher: "aes-256-gcm", Password: "p:s@s",
+			},
+		},
+		{
+			name: "旧版整体base64",
+			uri:  "ss://" + std("aes-128-gcm:pa:ss@1.2.3.4:8388") + "#legacy",
+			want: Node{
+				Protocol: ProtocolShadowsocks, Name: "legacy", Server: "1.2.3.4", Port: 8388,
+				Cipher: "aes-128-gcm", Password: "pa:ss",
+			},
+		},
+		{
+			name: "2022 aes-128",
+			uri:  "ss://2022-blake3-aes-128-gcm:" + url.PathEscape(ssKey16) + "@ss.example.com:443#2022",
+			want: Node{
+				Protocol: ProtocolShadowsocks, Name: "2022", Server: "ss.example.com", Port: 443,
+				Cipher: "2022-blake3-aes-128-gcm", Password: ssKey16,
+			},
+		},
+		{
+			name: "2022 多用户",
+			uri:  "ss://" + std("2022-blake3-aes-256-gcm:"+ssPSK32+":"+ssKey32) + "@ss.example.com:443#multi",
+			want: Node{
+				Protocol: ProtocolShadowsocks, Name: "multi", Server: "ss.example.com", Port: 443,
+				Cipher: "2022-blake3-aes-256-gcm", Password: ssPSK32 + ":" + ssKey32,
+			},
+		},
+	})
+}
+
+func TestParseShadowsocksInvalid(t *testing.T) {
+	std := func(s string) string { return base64.StdEncoding.EncodeToString([]byte(s)) }
+
+	testParseInvalid(t, []string{
+		"ss://" + std("rc2-cfb:pass") + "@ss.example.com:8388",
+		"ss://" + std(":pass") + "@ss.example.com:8388",
+		"ss://" + std("aes-256-gcm:pass") + "@ss.example.com",
+		"ss://" + std("aes-256-gcm:pass") + "@ss.example.com:abc",
+		"ss://not-base64",
+		// 2022 密钥长度必须与加密方式一致
+		"ss://2022-blake3-aes-128-gcm:" + url.PathEscape(ssKey32) + "@ss.example.com:443",
+		"ss://2022-blake3-aes-256-gcm:" + url.PathEscape(ssKey16) + "@ss.example.com:443",
+		"ss://2022-blake3-chacha20-poly1305:short@ss.example.com:443",
+		"ss://" + std("2022-blake3-aes-256-gcm:"+ssPSK32+":"+ssKey16) + "@ss.example.com:443",
+	})
+}
+
+func TestPluginOptions(t *testing.T) {
+	n := &Node{PluginOpts: "tls; mode=websocket;host=ws.example.com;path=/ws?ed=2048;;"}
+	want := map[string]string{"tls": "true", "mode": "websocket", "host": "ws.example.com", "path": "/ws?ed=2048"}
+	if got := n.PluginOptions(); !reflect.DeepEqual(got, want) {
+		t.Errorf("得到 %v，期望 %v", got, want)
+	}
+}
+
+func TestShadowsocksRoundTrip(t *testing.T) {
+	for _, uri := range []string{
+		"ss://" + base64.RawURLEncoding.EncodeToString([]byte("aes-256-gcm:p:ss")) + "@ss.example.com:8388/?plugin=" + url.QueryEscape("obfs-local;obfs=tls") + "#RT",
+		"ss://2022-blake3-aes-128-gcm:" + url.PathEscape(ssKey16) + "@ss.example.com:443#RT%202022",
+	} {
+		n, err := Parse(uri)
+		if err != nil {
+			t.Fatal(err)
+		}
+		again, err := Parse(n.URI())
+		if err != nil {
+			t.Fatalf("重新解析 %q 失败: %v", n.URI(), err)
+		}
+		if !reflect.DeepEqual(n, again) {
+			t.Errorf("编码后重新解析不一致\n原始 %+v\n得到 %+v", *n, *again)
+		}
+	}
+}
+
+func TestParseSSR(t *testing.T) {
+	std := func(s string) string { return base64.StdEncoding.EncodeToString([]byte(s)) }
+	rawURL := func(s string) string { return base64.RawURLEncoding.EncodeToString([]byte(s)) }
+
+	// 名称和混淆参数的标准 base64 编码包含 "+" 和 "/"
+	remarks := "🇭🇰 香港 01"
+	testParse(t, []parseCase{
+		{
+			name: "标准base64参数",
+			uri: "ssr://" + rawURL("ssr.example.com:8443:auth_aes128_md5:aes-256-cfb:tls1.2_ticket_auth:"+rawURL("pass")+
+				"/?obfsparam="+std("x>>>~")+"&protoparam="+std("1024:key+")+"&remarks="+std(remarks)+"&group="+std("g")),
+			want: Node{
+				Protocol: ProtocolSSR, Name: remarks, Server: "ssr.example.com", Port: 8443,
+				Cipher: "aes-256-cfb", Password: "pass",
+				SSRProtocol: "auth_aes128_md5", SSRProtocolParam: "1024:key+",
+				Obfs: "tls1.2_ticket_auth", ObfsParam: "x>>>~",
+			},
+		},
+		{
+			name: "IPv6 和无参数",
+			uri:  "ssr://" + std("2001:db8::1:443:origin:none:plain:"+std("p:ss")),
+			want: Node{
+				Protocol: ProtocolSSR, Server: "2001:db8::1", Port: 443,
+				Cipher: "none", Password: "p:ss", SSRProtocol: "origin", Obfs: "plain",
+			},
+		},
+	})
+}
+
+func TestParseSSRInvalid(t *testing.T) {
+	rawURL := func(s string) string { return base64.RawURLEncoding.EncodeToString([]byte(s)) }
+	testParseInvalid(t, []string{
+		"ssr://" + rawURL("ssr.example.com:8443:origin:none:plain"),
+		"ssr://" + rawURL("ssr.example.com:abc:origin:none:plain:"+rawURL("pass")),
+		"ssr://" + rawURL("ssr.example.com:8443:origin:none:plain:!!!"),
+		"ssr://!!!",
+	})
+}
+
+func TestSSRRoundTrip(t *testing.T) {
+	n := &Node{
+		Protocol: ProtocolSSR, Name: "香港>>? 01", Server: "ssr.example.com", Port: 8443,
+		Cipher: "aes-256-cfb", Password: "pa:ss", SSRProtocol: "auth_chain_a", SSRProtocolParam: "1024:key+",
+		Obfs: "http_simple", ObfsParam: "cdn.example.com",
+	}
+	again, err := Parse(n.URI())
+	if err != nil {
+		t.Fatalf("重新解析 %q 失败: %v", n.URI(), err)
+	}
+	if !reflect.DeepEqual(n, again) {
+		t.Errorf("编码后重新解析不一致\n原始 %+v\n得到 %+v", *n, *again)
+	}
+}
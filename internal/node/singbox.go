@@ -0,0 +1,233 @@
+package node
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// flexList 兼容单个字符串和字符串数组的JSON字段
+type flexList []string
+
+func (l *flexList) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if len(data) > 0 && data[0] == '"' {
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+		*l = splitList(s)
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	*l = list
+	return nil
+}
+
+// singboxOutbound sing-box 配置中的单个出站
+type singboxOutbound struct {
+	Type              string   `json:"type"`
+	Tag               string   `json:"tag"`
+	Server            string   `json:"server"`
+	ServerPort        int      `json:"server_port"`
+	ServerPorts       flexList `json:"server_ports"`
+	UUID              string   `json:"uuid"`
+	Password          string   `json:"password"`
+	Method            string   `json:"method"`
+	Plugin            string   `json:"plugin"`
+	PluginOpts        string   `json:"plugin_opts"`
+	Security          string   `json:"security"`
+	AlterID           int      `json:"alter_id"`
+	Flow              string   `json:"flow"`
+	UpMbps            int      `json:"up_mbps"`
+	DownMbps          int      `json:"down_mbps"`
+	CongestionControl string   `json:"congestion_control"`
+	UDPRelayMode      string   `json:"udp_relay_mode"`
+	Obfs              *struct {
+		Type     string `json:"type"`
+		Password string `json:"password"`
+	} `json:"obfs"`
+	TLS *struct {
+		Enabled    bool     `json:"enabled"`
+		ServerName string   `json:"server_name"`
+		Insecure   bool     `json:"insecure"`
+		ALPN       flexList `json:"alpn"`
+		UTLS       *struct {
+			Enabled     bool   `json:"enabled"`
+			Fingerprint string `json:"fingerprint"`
+		} `json:"utls"`
+		Reality *struct {
+			Enabled   bool   `json:"enabled"`
+			PublicKey string `json:"public_key"`
+			ShortID   string `json:"short_id"`
+		} `json:"reality"`
+	} `json:"tls"`
+	Transport *struct {
+		Type         string            `json:"type"`
+		Host         flexList          `json:"host"`
+		Path         string            `json:"path"`
+		Headers      map[string]string `json:"headers"`
+		ServiceName  string            `json:"service_name"`
+		MaxEarlyData int               `json:"max_early_data"`
+	} `json:"transport"`
+}
+
+// singboxSkipTypes 不是代理节点的出站类型
+var singboxSkipTypes = map[string]bool{
+	"direct": true, "block": true, "dns": true, "selector": true, "urltest": true,
+}
+
+// parseSingBox 解析 sing-box 配置中的代理出站，代理组和内置出站会被忽略
+func parseSingBox(content string) ([]*Node, []*ParseError) {
+	var doc struct {
+		Outbounds []json.RawMessage `json:"outbounds"`
+	}
+	if err := json.Unmarshal([]byte(content), &doc); err != nil {
+		return nil, []*ParseError{structuredError(FormatSingBox, "outbounds", fmt.Errorf("解析sing-box配置失败: %w", err))}
+	}
+
+	var nodes []*Node
+	var errs []*ParseError
+	for i, raw := range doc.Outbounds {
+		var out singboxOutbound
+		if err := json.Unmarshal(raw, &out); err != nil {
+			errs = append(errs, structuredError(FormatSingBox, fmt.Sprintf("第 %d 个出站", i+1), err))
+			continue
+		}
+		if singboxSkipTypes[out.Type] {
+			continue
+		}
+		n, err := out.node()
+		if err != nil {
+			errs = append(errs, structuredError(out.Type, out.Tag, fmt.Errorf("解析sing-box出站失败: %w", err)))
+			continue
+		}
+		nodes = append(nodes, n)
+	}
+	return nodes, errs
+}
+
+// node 将 sing-box 出站转换为统一的节点模型
+func (o *singboxOutbound) node() (*Node, error) {
+	n := &Node{
+		Name:   o.Tag,
+		Server: o.Server,
+		Port:   o.ServerPort,
+	}
+
+	switch o.Type {
+	case "vmess":
+		n.Protocol = ProtocolVMess
+		n.UUID = o.UUID
+		n.Cipher = firstNonEmpty(o.Security, "auto")
+		n.AlterID = o.AlterID
+		n.Transport = o.transport()
+		if n.Transport.Type == "" {
+			n.Transport.Type = "tcp"
+		}
+	case "vless":
+		n.Protocol = ProtocolVLESS
+		n.UUID = o.UUID
+		n.Cipher = "none"
+		n.Flow = o.Flow
+		n.Transport = o.transport()
+		if n.Transport.Type == "" {
+			n.Transport.Type = "tcp"
+		}
+	case "trojan":
+		n.Protocol = ProtocolTrojan
+		n.Password = o.Password
+		n.Transport = o.transport()
+	case "shadowsocks":
+		n.Protocol = ProtocolShadowsocks
+		n.Cipher = strings.ToLower(o.Method)
+		n.Password = o.Password
+		if err := validateSSCipher(n.Cipher, n.Password); err != nil {
+			return nil, err
+		}
+		n.Plugin = o.Plugin
+		n.PluginOpts = o.PluginOpts
+	case "hysteria2":
+		n.Protocol = ProtocolHysteria2
+		n.Password = o.Password
+		n.UpMbps = o.UpMbps
+		n.DownMbps = o.DownMbps
+		if o.Obfs != nil {
+			n.Obfs = o.Obfs.Type
+			n.ObfsPassword = o.Obfs.Password
+		}
+		if len(o.ServerPorts) > 0 {
+			port, hops := resolvePorts(o.ServerPorts)
+			if n.Port == 0 {
+				n.Port = port
+			}
+			n.Ports = hops
+		}
+	case "tuic":
+		n.Protocol = ProtocolTUIC
+		n.UUID = o.UUID
+		n.Password = o.Password
+		n.CongestionControl = o.CongestionControl
+		n.UDPRelayMode = o.UDPRelayMode
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupported, o.Type)
+	}
+
+	if o.TLS != nil && o.TLS.Enabled {
+		n.TLS = TLS{
+			Enabled:  true,
+			SNI:      o.TLS.ServerName,
+			Insecure: o.TLS.Insecure,
+			ALPN:     o.TLS.ALPN,
+		}
+		if o.TLS.UTLS != nil && o.TLS.UTLS.Enabled {
+			n.TLS.Fingerprint = o.TLS.UTLS.Fingerprint
+		}
+		if r := o.TLS.Reality; r != nil && r.Enabled {
+			if r.PublicKey == "" {
+				return nil, errors.New("REALITY缺少公钥")
+			}
+			n.TLS.Reality = &Reality{PublicKey: r.PublicKey, ShortID: r.ShortID}
+		}
+	}
+	// Trojan、Hysteria2 和 TUIC 总是使用TLS
+	switch n.Protocol {
+	case ProtocolTrojan, ProtocolHysteria2, ProtocolTUIC:
+		n.TLS.Enabled = true
+	}
+
+	if !validAddress(n) {
+		return nil, errors.New("服务器地址或端口无效")
+	}
+	return n, nil
+}
+
+// transport 转换传输层配置，early data 还原为路径中的 ?ed= 参数
+func (o *singboxOutbound) transport() Transport {
+	tr := o.Transport
+	if tr == nil {
+		return Transport{}
+	}
+
+	switch tr.Type {
+	case "ws":
+		t := Transport{Type: "ws", Path: tr.Path, Host: headerValue(tr.Headers, "Host")}
+		if tr.MaxEarlyData > 0 && !strings.Contains(t.Path, "?") {
+			t.Path += "?ed=" + strconv.Itoa(tr.MaxEarlyData)
+		}
+		return t
+	case "httpupgrade":
+		return Transport{Type: "httpupgrade", Path: tr.Path, Host: strings.Join(tr.Host, ",")}
+	case "grpc":
+		return Transport{Type: "grpc", ServiceName: tr.ServiceName}
+	case "http":
+		return Transport{Type: "h2", Path: tr.Path, Host: strings.Join(tr.Host, ",")}
+	}
+	return Transport{Type: tr.Type}
+}
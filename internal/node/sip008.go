@@ -0,0 +1,70 @@
+package node
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// sip008Server SIP008 在线配置中的单个服务器
+type sip008Server struct {
+	ID         string     `json:"id"`
+	Remarks    string     `json:"remarks"`
+	Server     string     `json:"server"`
+	ServerPort flexString `json:"server_port"`
+	Password   string     `json:"password"`
+	Method     string     `json:"method"`
+	Plugin     string     `json:"plugin"`
+	PluginOpts string     `json:"plugin_opts"`
+}
+
+// parseSIP008 解析 SIP008 格式的 Shadowsocks 在线配置
+func parseSIP008(content string) ([]*Node, []*ParseError) {
+	var doc struct {
+		Servers []json.RawMessage `json:"servers"`
+	}
+	if err := json.Unmarshal([]byte(content), &doc); err != nil {
+		return nil, []*ParseError{structuredError(FormatSIP008, "servers", fmt.Errorf("解析SIP008配置失败: %w", err))}
+	}
+
+	var nodes []*Node
+	var errs []*ParseError
+	for i, raw := range doc.Servers {
+		var s sip008Server
+		if err := json.Unmarshal(raw, &s); err != nil {
+			errs = append(errs, structuredError(FormatSIP008, fmt.Sprintf("第 %d 个服务器", i+1), err))
+			continue
+		}
+		n, err := s.node()
+		if err != nil {
+			errs = append(errs, structuredError("ss", firstNonEmpty(s.Remarks, s.ID), fmt.Errorf("解析SIP008服务器失败: %w", err)))
+			continue
+		}
+		nodes = append(nodes, n)
+	}
+	return nodes, errs
+}
+
+// node 将 SIP008 服务器转换为 Shadowsocks 节点
+func (s *sip008Server) node() (*Node, error) {
+	n := &Node{
+		Protocol:   ProtocolShadowsocks,
+		Name:       firstNonEmpty(s.Remarks, s.ID),
+		Server:     s.Server,
+		Cipher:     strings.ToLower(s.Method),
+		Password:   s.Password,
+		Plugin:     s.Plugin,
+		PluginOpts: s.PluginOpts,
+	}
+	n.Port, _ = strconv.Atoi(string(s.ServerPort))
+
+	if err := validateSSCipher(n.Cipher, n.Password); err != nil {
+		return nil, err
+	}
+	if !validAddress(n) {
+		return nil, errors.New("服务器地址或端口无效")
+	}
+	return n, nil
+}
@@ -0,0 +1,104 @@
+package node
+
+import (
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+)
+
+func init() {
+	Register(ProtocolSSR, []string{"ssr"}, parseSSR, formatSSR)
+}
+
+// parseSSR 解析 ssr://base64(host:port:protocol:method:obfs:base64pass/?params) 格式
+func parseSSR(uri string) (*Node, error) {
+	data, err := DecodeBase64(uri[strings.Index(uri, "://")+3:])
+	if err != nil {
+		return nil, err
+	}
+
+	main, rawQuery := string(data), ""
+	if idx := strings.Index(main, "/?"); idx >= 0 {
+		main, rawQuery = main[:idx], main[idx+2:]
+	} else if idx := strings.Index(main, "?"); idx >= 0 {
+		main, rawQuery = main[:idx], main[idx+1:]
+	}
+
+	// 主机可能是包含冒号的IPv6地址，因此从右侧拆分
+	fields := strings.Split(main, ":")
+	if len(fields) < 6 {
+		return nil, errors.New("字段数量不足")
+	}
+	tail := fields[len(fields)-5:]
+	host := strings.Trim(strings.Join(fields[:len(fields)-5], ":"), "[]")
+
+	port, err := strconv.Atoi(tail[0])
+	if err != nil {
+		return nil, errors.New("端口无效")
+	}
+	password, err := DecodeBase64(tail[4])
+	if err != nil {
+		return nil, errors.New("密码无效")
+	}
+
+	q := parseSSRQuery(rawQuery)
+	n := &Node{
+		Protocol:         ProtocolSSR,
+		Name:             decodeSSRParam(q["remarks"]),
+		Server:           host,
+		Port:             port,
+		Cipher:           tail[2],
+		Password:         string(password),
+		SSRProtocol:      tail[1],
+		SSRProtocolParam: decodeSSRParam(q["protoparam"]),
+		Obfs:             tail[3],
+		ObfsParam:        decodeSSRParam(q["obfsparam"]),
+	}
+	return n, nil
+}
+
+// formatSSR 编码为 ssr:// 链接
+func formatSSR(n *Node) string {
+	enc := base64.RawURLEncoding
+	main := strings.Join([]string{
+		n.Server,
+		strconv.Itoa(n.Port),
+		n.SSRProtocol,
+		n.Cipher,
+		n.Obfs,
+		enc.EncodeToString([]byte(n.Password)),
+	}, ":")
+
+	params := []string{
+		"obfsparam=" + enc.EncodeToString([]byte(n.ObfsParam)),
+		"protoparam=" + enc.EncodeToString([]byte(n.SSRProtocolParam)),
+		"remarks=" + enc.EncodeToString([]byte(n.Name)),
+	}
+	return "ssr://" + enc.EncodeToString([]byte(main+"/?"+strings.Join(params, "&")))
+}
+
+// parseSSRQuery 拆分 SSR 链接参数。参数值为base64，可能包含 "+"，不能按URL查询参数解码，
+// 否则 "+" 会变成空格
+func parseSSRQuery(rawQuery string) map[string]string {
+	params := make(map[string]string)
+	for _, item := range strings.Split(rawQuery, "&") {
+		key, value, _ := strings.Cut(item, "=")
+		if _, exists := params[key]; key != "" && !exists {
+			params[key] = value
+		}
+	}
+	return params
+}
+
+// decodeSSRParam 解码base64编码的参数，失败时返回空字符串
+func decodeSSRParam(s string) string {
+	if s == "" {
+		return ""
+	}
+	data, err := DecodeBase64(s)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
@@ -0,0 +1,66 @@
+package node
+
+import (
+	"net/url"
+	"strconv"
+)
+
+func init() {
+	Register(ProtocolTrojan, []string{"trojan"}, parseTrojan, formatTrojan)
+}
+
+// parseTrojan 解析 trojan://password@host:port?params#name 格式
+func parseTrojan(uri string) (*Node, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, err
+	}
+
+	n := &Node{
+		Protocol: ProtocolTrojan,
+		Name:     u.Fragment,
+		Server:   u.Hostname(),
+		Port:     443,
+	}
+	if u.User != nil {
+		n.Password = u.User.Username()
+	}
+	if port := u.Port(); port != "" {
+		n.Port, _ = strconv.Atoi(port)
+	}
+
+	q := u.Query()
+	n.TLS = TLS{
+		Enabled:     true,
+		SNI:         firstNonEmpty(q.Get("sni"), q.Get("peer"), n.Server),
+		Fingerprint: q.Get("fp"),
+		Insecure:    parseBool(q.Get("allowInsecure")) || parseBool(q.Get("insecure")),
+	}
+	if alpn := q.Get("alpn"); alpn != "" {
+		n.TLS.ALPN = splitList(alpn)
+	}
+	parseTransportQuery(n, q)
+	return n, nil
+}
+
+// formatTrojan 编码为 trojan:// 链接
+func formatTrojan(n *Node) string {
+	q := url.Values{}
+	if n.TLS.SNI != "" {
+		q.Set("sni", n.TLS.SNI)
+	}
+	if n.TLS.Insecure {
+		q.Set("allowInsecure", "1")
+	}
+	setTLSQuery(q, n.TLS)
+	setTransportQuery(q, n.Transport)
+
+	u := url.URL{
+		Scheme:   "trojan",
+		User:     url.User(n.Password),
+		Host:     n.Address(),
+		RawQuery: q.Encode(),
+		Fragment: n.Name,
+	}
+	return u.String()
+}
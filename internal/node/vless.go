@@ -0,0 +1,120 @@
+package node
+
+import (
+	"errors"
+	"net/url"
+	"strconv"
+)
+
+func init() {
+	Register(ProtocolVLESS, []string{"vless"}, parseVLESS, formatVLESS)
+}
+
+// parseVLESS 解析 vless://uuid@host:port?security=tls|reality&type=ws#name 格式
+func parseVLESS(uri string) (*Node, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, err
+	}
+	if u.User == nil || u.User.Username() == "" {
+		return nil, errors.New("缺少UUID")
+	}
+	port, err := strconv.Atoi(u.Port())
+	if err != nil {
+		return nil, errors.New("端口无效")
+	}
+
+	q := u.Query()
+	n := &Node{
+		Protocol: ProtocolVLESS,
+		Name:     u.Fragment,
+		Server:   u.Hostname(),
+		Port:     port,
+		UUID:     u.User.Username(),
+		Cipher:   firstNonEmpty(q.Get("encryption"), "none"),
+		Flow:     q.Get("flow"),
+	}
+
+	parseTransportQuery(n, q)
+	if n.Transport.Type == "" || n.Transport.Type == "raw" {
+		n.Transport.Type = "tcp"
+	}
+	if n.Transport.Type == "grpc" {
+		n.Transport.HeaderType = q.Get("mode")
+	}
+
+	switch security := q.Get("security"); security {
+	case "tls", "xtls", "reality":
+		n.TLS = TLS{
+			Enabled:     true,
+			SNI:         firstNonEmpty(q.Get("sni"), q.Get("peer")),
+			Fingerprint: q.Get("fp"),
+			Insecure:    parseBool(q.Get("allowInsecure")) || parseBool(q.Get("insecure")),
+		}
+		if alpn := q.Get("alpn"); alpn != "" {
+			n.TLS.ALPN = splitList(alpn)
+		}
+		if security == "reality" {
+			if q.Get("pbk") == "" {
+				return nil, errors.New("REALITY缺少公钥")
+			}
+			n.TLS.Reality = &Reality{
+				PublicKey: q.Get("pbk"),
+				ShortID:   q.Get("sid"),
+				SpiderX:   q.Get("spx"),
+			}
+		}
+	case "", "none":
+	default:
+		return nil, errors.New("不支持的安全类型: " + security)
+	}
+	return n, nil
+}
+
+// formatVLESS 编码为 vless:// 链接
+func formatVLESS(n *Node) string {
+	q := url.Values{}
+	q.Set("encryption", firstNonEmpty(n.Cipher, "none"))
+	if n.Flow != "" {
+		q.Set("flow", n.Flow)
+	}
+
+	switch {
+	case n.TLS.Reality != nil:
+		q.Set("security", "reality")
+		q.Set("pbk", n.TLS.Reality.PublicKey)
+		if n.TLS.Reality.ShortID != "" {
+			q.Set("sid", n.TLS.Reality.ShortID)
+		}
+		if n.TLS.Reality.SpiderX != "" {
+			q.Set("spx", n.TLS.Reality.SpiderX)
+		}
+	case n.TLS.Enabled:
+		q.Set("security", "tls")
+	default:
+		q.Set("security", "none")
+	}
+	if n.TLS.SNI != "" {
+		q.Set("sni", n.TLS.SNI)
+	}
+	if n.TLS.Insecure {
+		q.Set("allowInsecure", "1")
+	}
+	setTLSQuery(q, n.TLS)
+
+	q.Set("type", firstNonEmpty(n.Transport.Type, "tcp"))
+	setTransportQuery(q, n.Transport)
+	if n.Transport.Type == "grpc" && n.Transport.HeaderType != "" {
+		q.Del("headerType")
+		q.Set("mode", n.Transport.HeaderType)
+	}
+
+	u := url.URL{
+		Scheme:   "vless",
+		User:     url.User(n.UUID),
+		Host:     n.Address(),
+		RawQuery: q.Encode(),
+		Fragment: n.Name,
+	}
+	return u.String()
+}
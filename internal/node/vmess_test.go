@@ -0,0 +1,146 @@
+package node
+
+import (
+	"encoding/base64"
+	"reflect"
+	"testing"
+)
+
+func TestParseVMess(t *testing.T) {
+	std := func(s string) string { return base64.StdEncoding.EncodeToString([]byte(s)) }
+	rawURL := func(s string) string { return base64.RawURLEncoding.EncodeToString([]byte(s)) }
+
+	tests := []struct {
+		name string
+		uri  string
+		want Node
+	}{
+		{
+			name: "v2rayN ws tls 字符串端口",
+			uri: "vmess://" + std(`{"v":"2","ps":"香港 01","add":"hk.example.com","port":"443","id":"b831381d-6324-4d53-ad4f-8cda48b30811",`+
+				`"aid":"0","scy":"auto","net":"ws","type":"none","host":"cdn.example.com","path":"/ray","tls":"tls","sni":"hk.example.com","alpn":"h2,http/1.1","fp":"chrome"}`),
+			want: Node{
+				Protocol: ProtocolVMess, Name: "香港 01", Server: "hk.example.com", Port: 443,
+				UUID: "b831381d-6324-4d53-ad4f-8cda48b30811", Cipher: "auto",
+				Transport: Transport{Type: "ws", Host: "cdn.example.com", Path: "/ray"},
+				TLS:       TLS{Enabled: true, SNI: "hk.example.com", ALPN: []string{"h2", "http/1.1"}, Fingerprint: "chrome"},
+			},
+		},
+		{
+			name: "v2rayN 数字端口和aid",
+			uri:  "vmess://" + std(`{"v":2,"ps":"JP","add":"1.2.3.4","port":8443,"id":"uuid-1","aid":64,"net":"tcp","type":"none","tls":""}`),
+			want: Node{
+				Protocol: ProtocolVMess, Name: "JP", Server: "1.2.3.4", Port: 8443,
+				UUID: "uuid-1", Cipher: "auto", AlterID: 64,
+				Transport: Transport{Type: "tcp"},
+			},
+		},
+		{
+			name: "v2rayN URL安全无填充base64",
+			uri:  "vmess://" + rawURL(`{"v":"2","ps":"美国>>?","add":"us.example.com","port":"2053","id":"uuid-2","aid":"0","scy":"aes-128-gcm","net":"grpc","type":"gun","path":"svc","tls":"tls","allowInsecure":true}`),
+			want: Node{
+				Protocol: ProtocolVMess, Name: "美国>>?", Server: "us.example.com", Port: 2053,
+				UUID: "uuid-2", Cipher: "aes-128-gcm",
+				Transport: Transport{Type: "grpc", ServiceName: "svc", HeaderType: "gun"},
+				TLS:       TLS{Enabled: true, Insecure: true},
+			},
+		},
+		{
+			name: "v2rayN h2",
+			uri:  "vmess://" + std(`{"v":"2","ps":"h2","add":"h2.example.com","port":"443","id":"uuid-3","aid":"0","net":"h2","host":"h2.example.com","path":"/h2","tls":"tls"}`),
+			want: Node{
+				Protocol: ProtocolVMess, Name: "h2", Server: "h2.example.com", Port: 443,
+				UUID: "uuid-3", Cipher: "auto",
+				Transport: Transport{Type: "h2", Host: "h2.example.com", Path: "/h2"},
+				TLS:       TLS{Enabled: true},
+			},
+		},
+		{
+			name: "v2rayN httpupgrade",
+			uri:  "vmess://" + std(`{"v":"2","ps":"hu","add":"hu.example.com","port":"80","id":"uuid-4","aid":"0","net":"httpupgrade","host":"hu.example.com","path":"/up"}`),
+			want: Node{
+				Protocol: ProtocolVMess, Name: "hu", Server: "hu.example.com", Port: 80,
+				UUID: "uuid-4", Cipher: "auto",
+				Transport: Transport{Type: "httpupgrade", Host: "hu.example.com", Path: "/up"},
+			},
+		},
+		{
+			name: "Shadowrocket websocket",
+			uri:  "vmess://" + rawURL("auto:uuid-5@sr.example.com:443") + "?remarks=SR%20Node&obfs=websocket&obfsParam=cdn.example.com&path=/ws&tls=1&peer=sr.example.com&alterId=2",
+			want: Node{
+				Protocol: ProtocolVMess, Name: "SR Node", Server: "sr.example.com", Port: 443,
+				UUID: "uuid-5", Cipher: "auto", AlterID: 2,
+				Transport: Transport{Type: "ws", Host: "cdn.example.com", Path: "/ws"},
+				TLS:       TLS{Enabled: true, SNI: "sr.example.com"},
+			},
+		},
+		{
+			name: "Shadowrocket 默认tcp",
+			uri:  "vmess://" + std("chacha20-poly1305:uuid-6@10.0.0.1:10086") + "#plain",
+			want: Node{
+				Protocol: ProtocolVMess, Name: "plain", Server: "10.0.0.1", Port: 10086,
+				UUID: "uuid-6", Cipher: "chacha20-poly1305",
+				Transport: Transport{Type: "tcp"},
+			},
+		},
+		{
+			name: "uuid@host:port grpc",
+			uri:  "vmess://uuid-7@grpc.example.com:443?type=grpc&serviceName=gsvc&mode=multi&security=tls&sni=grpc.example.com#gRPC",
+			want: Node{
+				Protocol: ProtocolVMess, Name: "gRPC", Server: "grpc.example.com", Port: 443,
+				UUID: "uuid-7", Cipher: "auto",
+				Transport: Transport{Type: "grpc", ServiceName: "gsvc", HeaderType: "multi"},
+				TLS:       TLS{Enabled: true, SNI: "grpc.example.com"},
+			},
+		},
+		{
+			name: "uuid@host:port httpupgrade",
+			uri:  "vmess://uuid-8@[2001:db8::1]:8080?type=httpupgrade&host=hu.example.com&path=%2Fup&alterId=1#v6",
+			want: Node{
+				Protocol: ProtocolVMess, Name: "v6", Server: "2001:db8::1", Port: 8080,
+				UUID: "uuid-8", Cipher: "auto", AlterID: 1,
+				Transport: Transport{Type: "httpupgrade", Host: "hu.example.com", Path: "/up"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n, err := Parse(tt.uri)
+			if err != nil {
+				t.Fatalf("Parse(%q) 失败: %v", tt.uri, err)
+			}
+			if !reflect.DeepEqual(*n, tt.want) {
+				t.Errorf("Parse(%q)\n得到 %+v\n期望 %+v", tt.uri, *n, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseVMessInvalid(t *testing.T) {
+	for _, uri := range []string{
+		"vmess://",
+		"vmess://" + base64.StdEncoding.EncodeToString([]byte(`{"add":"a.com","port":"abc","id":"x"}`)),
+		"vmess://" + base64.StdEncoding.EncodeToString([]byte(`{"add":"","port":"443","id":"x"}`)),
+		"vmess://not-base64-and-no-at",
+	} {
+		if n, err := Parse(uri); err == nil {
+			t.Errorf("Parse(%q) 应失败，得到 %+v", uri, *n)
+		}
+	}
+}
+
+func TestVMessRoundTrip(t *testing.T) {
+	uri := "vmess://uuid-9@rt.example.com:443?type=ws&host=cdn.example.com&path=%2Fws&security=tls&sni=rt.example.com&alpn=h2#RT"
+	n, err := Parse(uri)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := Parse(n.URI())
+	if err != nil {
+		t.Fatalf("重新解析 %q 失败: %v", n.URI(), err)
+	}
+	if !reflect.DeepEqual(n, again) {
+		t.Errorf("编码后重新解析不一致\n原始 %+v\n得到 %+v", *n, *again)
+	}
+}
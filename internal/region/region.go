@@ -0,0 +1,188 @@
+package region
+
+import (
+	"strings"
+)
+
+// Region 节点所在地区
+type Region struct {
+	// Code ISO 3166 两位代码，英国使用 GB
+	Code string
+	Name string
+	// EnglishName 英文名称
+	EnglishName string
+	Flag        string
+	// keywords 节点名称中代表该地区的中文名、城市名或英文名
+	keywords []string
+	// codes 节点名称中代表该地区的大写代码，如 HK、HKG
+	codes []string
+}
+
+// regions 可识别的地区，按常见程度排序
+var regions = []Region{
+	{Code: "HK", Name: "香港", EnglishName: "Hong Kong", Flag: "🇭🇰",
+		keywords: []string{"香港", "港", "hong kong", "hongkong"}, codes: []string{"HK", "HKG"}},
+	{Code: "TW", Name: "台湾", EnglishName: "Taiwan", Flag: "🇹🇼",
+		keywords: []string{"台湾", "台灣", "台北", "新北", "彰化", "taiwan", "taipei"}, codes: []string{"TW", "TWN"}},
+	{Code: "JP", Name: "日本", EnglishName: "Japan", Flag: "🇯🇵",
+		keywords: []string{"日本", "东京", "東京", "大阪", "japan", "tokyo", "osaka"}, codes: []string{"JP", "JPN"}},
+	{Code: "SG", Name: "新加坡", EnglishName: "Singapore", Flag: "🇸🇬",
+		keywords: []string{"新加坡", "狮城", "獅城", "singapore"}, codes: []string{"SG", "SGP"}},
+	{Code: "US", Name: "美国", EnglishName: "United States", Flag: "🇺🇸",
+		keywords: []string{"美国", "美國", "洛杉矶", "硅谷", "圣何塞", "西雅图", "芝加哥", "纽约", "达拉斯", "凤凰城",
+			"united states", "america", "los angeles", "san jose", "silicon valley", "seattle", "chicago", "new york", "dallas"},
+		codes: []string{"US", "USA"}},
+	{Code: "KR", Name: "韩国", EnglishName: "Korea", Flag: "🇰🇷",
+		keywords: []string{"韩国", "韓國", "首尔", "首爾", "春川", "korea", "seoul"}, codes: []string{"KR", "KOR"}},
+	{Code: "GB", Name: "英国", EnglishName: "United Kingdom", Flag: "🇬🇧",
+		keywords: []string{"英国", "英國", "伦敦", "united kingdom", "britain", "london"}, codes: []string{"GB", "UK", "GBR"}},
+	{Code: "DE", Name: "德国", EnglishName: "Germany", Flag: "🇩🇪",
+		keywords: []string{"德国", "德國", "法兰克福", "germany", "frankfurt"}, codes: []string{"DE", "DEU"}},
+	{Code: "FR", Name: "法国", EnglishName: "France", Flag: "🇫🇷",
+		keywords: []string{"法国", "法國", "巴黎", "france", "paris"}, codes: []string{"FR", "FRA"}},
+	{Code: "NL", Name: "荷兰", EnglishName: "Netherlands", Flag: "🇳🇱",
+		keywords: []string{"荷兰", "荷蘭", "阿姆斯特丹", "netherlands", "amsterdam"}, codes: []string{"NL", "NLD"}},
+	{Code: "CA", Name: "加拿大", EnglishName: "Canada", Flag: "🇨🇦",
+		keywords: []string{"加拿大", "多伦多", "温哥华", "canada", "toronto", "vancouver"}, codes: []string{"CA", "CAN"}},
+	{Code: "AU", Name: "澳大利亚", EnglishName: "Australia", Flag: "🇦🇺",
+		keywords: []string{"澳大利亚", "澳洲", "悉尼", "墨尔本", "australia", "sydney", "melbourne"}, codes: []string{"AU", "AUS"}},
+	{Code: "MO", Name: "澳门", EnglishName: "Macau", Flag: "🇲🇴",
+		keywords: []string{"澳门", "澳門", "macau", "macao"}, codes: []string{"MO", "MAC"}},
+	{Code: "RU", Name: "俄罗斯", EnglishName: "Russia", Flag: "🇷🇺",
+		keywords: []string{"俄罗斯", "俄羅斯", "莫斯科", "russia", "moscow"}, codes: []string{"RU", "RUS"}},
+	{Code: "IN", Name: "印度", EnglishName: "India", Flag: "🇮🇳",
+		keywords: []string{"印度", "孟买", "india", "mumbai"}, codes: []string{"IN", "IND"}},
+	{Code: "ID", Name: "印度尼西亚", EnglishName: "Indonesia", Flag: "🇮🇩",
+		keywords: []string{"印度尼西亚", "印尼", "雅加达", "indonesia", "jakarta"}, codes: []string{"ID", "IDN"}},
+	{Code: "MY", Name: "马来西亚", EnglishName: "Malaysia", Flag: "🇲🇾",
+		keywords: []string{"马来西亚", "馬來西亞", "吉隆坡", "malaysia", "kuala lumpur"}, codes: []string{"MY", "MYS"}},
+	{Code: "TH", Name: "泰国", EnglishName: "Thailand", Flag: "🇹🇭",
+		keywords: []string{"泰国", "泰國", "曼谷", "thailand", "bangkok"}, codes: []string{"TH", "THA"}},
+	{Code: "VN", Name: "越南", EnglishName: "Vietnam", Flag: "🇻🇳",
+		keywords: []string{"越南", "胡志明", "vietnam", "hanoi"}, codes: []string{"VN", "VNM"}},
+	{Code: "PH", Name: "菲律宾", EnglishName: "Philippines", Flag: "🇵🇭",
+		keywords: []string{"菲律宾", "菲律賓", "马尼拉", "philippines", "manila"}, codes: []string{"PH", "PHL"}},
+	{Code: "TR", Name: "土耳其", EnglishName: "Turkey", Flag: "🇹🇷",
+		keywords: []string{"土耳其", "伊斯坦布尔", "turkey", "istanbul"}, codes: []string{"TR", "TUR"}},
+	{Code: "AE", Name: "阿联酋", EnglishName: "United Arab Emirates", Flag: "🇦🇪",
+		keywords: []string{"阿联酋", "迪拜", "united arab emirates", "dubai"}, codes: []string{"AE", "UAE", "ARE"}},
+	{Code: "IT", Name: "意大利", EnglishName: "Italy", Flag: "🇮🇹",
+		keywords: []string{"意大利", "米兰", "italy", "milan"}, codes: []string{"IT", "ITA"}},
+	{Code: "ES", Name: "西班牙", EnglishName: "Spain", Flag: "🇪🇸",
+		keywords: []string{"西班牙", "马德里", "spain", "madrid"}, codes: []string{"ES", "ESP"}},
+	{Code: "CH", Name: "瑞士", EnglishName: "Switzerland", Flag: "🇨🇭",
+		keywords: []string{"瑞士", "苏黎世", "switzerland", "zurich"}, codes: []string{"CH", "CHE"}},
+	{Code: "SE", Name: "瑞典", EnglishName: "Sweden", Flag: "🇸🇪",
+		keywords: []string{"瑞典", "斯德哥尔摩", "sweden", "stockholm"}, codes: []string{"SE", "SWE"}},
+	{Code: "IE", Name: "爱尔兰", EnglishName: "Ireland", Flag: "🇮🇪",
+		keywords: []string{"爱尔兰", "愛爾蘭", "都柏林", "ireland", "dublin"}, codes: []string{"IE", "IRL"}},
+	{Code: "PL", Name: "波兰", EnglishName: "Poland", Flag: "🇵🇱",
+		keywords: []string{"波兰", "華沙", "华沙", "poland", "warsaw"}, codes: []string{"PL", "POL"}},
+	{Code: "UA", Name: "乌克兰", EnglishName: "Ukraine", Flag: "🇺🇦",
+		keywords: []string{"乌克兰", "烏克蘭", "基辅", "ukraine", "kyiv"}, codes: []string{"UA", "UKR"}},
+	{Code: "BR", Name: "巴西", EnglishName: "Brazil", Flag: "🇧🇷",
+		keywords: []string{"巴西", "圣保罗", "brazil", "sao paulo"}, codes: []string{"BR", "BRA"}},
+	{Code: "AR", Name: "阿根廷", EnglishName: "Argentina", Flag: "🇦🇷",
+		keywords: []string{"阿根廷", "argentina", "buenos aires"}, codes: []string{"AR", "ARG"}},
+}
+
+// All 返回所有可识别的地区
+func All() []Region {
+	return regions
+}
+
+// Lookup 根据地区代码查找地区，UK 视为 GB
+func Lookup(code string) (Region, bool) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if code == "UK" {
+		code = "GB"
+	}
+	for _, r := range regions {
+		if r.Code == code {
+			return r, true
+		}
+	}
+	return Region{}, false
+}
+
+// Detect 根据节点名称识别地区：依次匹配旗帜、中英文名称和地区代码，
+// 同一类中取名称里最靠前的匹配，位置相同时取更长的关键词（如印度尼西亚优先于印度）
+func Detect(name string) (Region, bool) {
+	for _, r := range regions {
+		if strings.Contains(name, r.Flag) {
+			return r, true
+		}
+	}
+
+	lower := strings.ToLower(name)
+	if r, ok := earliest(func(r Region) (int, int) {
+		best, length := -1, 0
+		for _, k := range r.keywords {
+			var i int
+			if isASCII(k) {
+				i = indexWord(lower, k)
+			} else {
+				i = strings.Index(name, k)
+			}
+			if i >= 0 && (best < 0 || i < best || i == best && len(k) > length) {
+				best, length = i, len(k)
+			}
+		}
+		return best, length
+	}); ok {
+		return r, true
+	}
+
+	return earliest(func(r Region) (int, int) {
+		best, length := -1, 0
+		for _, c := range r.codes {
+			if i := indexWord(name, c); i >= 0 && (best < 0 || i < best || i == best && len(c) > length) {
+				best, length = i, len(c)
+			}
+		}
+		return best, length
+	})
+}
+
+// earliest 返回匹配位置最靠前的地区
+func earliest(find func(Region) (int, int)) (Region, bool) {
+	var result Region
+	best, length := -1, 0
+	for _, r := range regions {
+		i, l := find(r)
+		if i >= 0 && (best < 0 || i < best || i == best && l > length) {
+			result, best, length = r, i, l
+		}
+	}
+	return result, best >= 0
+}
+
+// indexWord 查找前后不紧邻英文字母的 word，如 "HK01" 中的 HK，但不匹配 "BONUS" 中的 US
+func indexWord(s, word string) int {
+	offset := 0
+	for {
+		i := strings.Index(s[offset:], word)
+		if i < 0 {
+			return -1
+		}
+		i += offset
+		end := i + len(word)
+		if (i == 0 || !isLetter(s[i-1])) && (end == len(s) || !isLetter(s[end])) {
+			return i
+		}
+		offset = i + 1
+	}
+}
+
+func isLetter(b byte) bool {
+	return b >= 'a' && b <= 'z' || b >= 'A' && b <= 'Z'
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= 0x80 {
+			return false
+		}
+	}
+	return true
+}
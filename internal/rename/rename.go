@@ -0,0 +1,193 @@
+package rename
+
+import (
+	"fmt"
+	"log"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"sublinks/config"
+	"sublinks/internal/node"
+	"sublinks/internal/region"
+)
+
+// unknownRegion 无法识别地区时模板中 {region} 的取值
+const unknownRegion = "其他"
+
+// placeholder 命名模板中的占位符，如 {index:02}
+var placeholder = regexp.MustCompile(`\{(\w+)(?::(\d+))?\}`)
+
+type rule struct {
+	re      *regexp.Regexp
+	replace string
+}
+
+// Renamer 按配置重命名节点
+type Renamer struct {
+	rules    []rule
+	template string
+	prefix   bool
+	suffix   bool
+	emoji    bool
+	// perRegion 模板包含 {region}、{code} 或 {flag}，{index} 按地区分别计数
+	perRegion bool
+}
+
+// New 编译重命名规则
+func New(cfg config.RenameConfig) (*Renamer, error) {
+	r := &Renamer{
+		template: cfg.Template,
+		prefix:   cfg.SourcePrefix,
+		suffix:   cfg.SourceSuffix,
+		emoji:    cfg.Emoji,
+	}
+	usesSource := false
+	for _, m := range placeholder.FindAllStringSubmatch(cfg.Template, -1) {
+		switch m[1] {
+		case "region", "code", "flag":
+			r.perRegion = true
+		case "source":
+			usesSource = true
+		}
+	}
+	// 模板中已有 {source} 时不再重复添加订阅源名称
+	if usesSource && (r.prefix || r.suffix) {
+		log.Printf("命名模板已包含 {source}，忽略 source_prefix 和 source_suffix")
+		r.prefix, r.suffix = false, false
+	}
+	for _, c := range cfg.Rules {
+		re, err := regexp.Compile(c.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("重命名规则 %q 无效: %w", c.Pattern, err)
+		}
+		r.rules = append(r.rules, rule{re: re, replace: c.Replace})
+	}
+	return r, nil
+}
+
+// Apply 重命名节点并保证名称唯一，reserved 中的名称（内置策略和代理组）视为已占用，
+// 原样透传的节点不受影响
+func (r *Renamer) Apply(nodes []*node.Node, reserved []string) {
+	indexes := make(map[string]int)
+	for _, n := range nodes {
+		if n.Protocol == node.ProtocolUnknown {
+			continue
+		}
+
+		original := n.Name
+		name := original
+		for _, rule := range r.rules {
+			name = rule.re.ReplaceAllString(name, rule.replace)
+		}
+		name = strings.TrimSpace(name)
+
+		// 替换规则可能去掉了地区信息，此时按原名称识别
+		reg, found := region.Detect(name)
+		if !found {
+			reg, found = region.Detect(original)
+		}
+
+		if r.template != "" {
+			// 模板中有地区信息时序号在同一地区内计数，否则全局计数
+			counter := ""
+			if r.perRegion {
+				counter = reg.Code
+			}
+			indexes[counter]++
+			if expanded := r.expand(n, name, reg, found, indexes[counter]); expanded != "" {
+				name = expanded
+			}
+		}
+		// 订阅源名称添加在模板展开后的名称上
+		if source := sourceLabel(n.Source); source != "" {
+			if r.prefix {
+				name = source + " " + name
+			}
+			if r.suffix {
+				name = name + " " + source
+			}
+		}
+
+		if r.emoji && found && !strings.Contains(name, reg.Flag) {
+			name = reg.Flag + " " + name
+		}
+		n.Name = strings.TrimSpace(name)
+	}
+
+	Uniquify(nodes, reserved)
+}
+
+// expand 展开命名模板，支持 {name} {region} {code} {flag} {source} {protocol} {index}，
+// {index:02} 表示补零到两位，序号从1开始，模板包含地区信息时在同一地区内计数
+func (r *Renamer) expand(n *node.Node, name string, reg region.Region, found bool, index int) string {
+	regionName := unknownRegion
+	if found {
+		regionName = reg.Name
+	}
+
+	result := placeholder.ReplaceAllStringFunc(r.template, func(m string) string {
+		parts := placeholder.FindStringSubmatch(m)
+		switch parts[1] {
+		case "name":
+			return name
+		case "region":
+			return regionName
+		case "code":
+			return reg.Code
+		case "flag":
+			return reg.Flag
+		case "source":
+			return sourceLabel(n.Source)
+		case "protocol":
+			return string(n.Protocol)
+		case "index":
+			if parts[2] != "" {
+				width, _ := strconv.Atoi(parts[2])
+				return fmt.Sprintf("%0*d", width, index)
+			}
+			return strconv.Itoa(index)
+		}
+		return m
+	})
+	return strings.TrimSpace(result)
+}
+
+// sourceLabel 返回用于名称的订阅源标识，URL 取主机名，主数据中的节点不添加来源
+func sourceLabel(source string) string {
+	if source == node.SourceMain {
+		return ""
+	}
+	if u, err := url.Parse(source); err == nil && u.Host != "" {
+		return u.Hostname()
+	}
+	return source
+}
+
+// Uniquify 为重名节点依次添加 " 2"、" 3" 等后缀，保证名称唯一，reserved 中的名称视为已占用。
+// 名称为空的节点需要先由调用方命名
+func Uniquify(nodes []*node.Node, reserved []string) {
+	used := make(map[string]bool, len(nodes)+len(reserved))
+	for _, name := range reserved {
+		used[name] = true
+	}
+	for _, n := range nodes {
+		if n.Protocol == node.ProtocolUnknown {
+			continue
+		}
+		if !used[n.Name] {
+			used[n.Name] = true
+			continue
+		}
+
+		for i := 2; ; i++ {
+			candidate := fmt.Sprintf("%s %d", n.Name, i)
+			if !used[candidate] {
+				n.Name = candidate
+				used[candidate] = true
+				break
+			}
+		}
+	}
+}
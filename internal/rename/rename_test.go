@@ -0,0 +1,86 @@
+package rename
+
+import (
+	"reflect"
+	"testing"
+
+	"sublinks/config"
+	"sublinks/internal/node"
+)
+
+func names(nodes []*node.Node) []string {
+	result := make([]string, len(nodes))
+	for i, n := range nodes {
+		result[i] = n.Name
+	}
+	return result
+}
+
+func TestApplyTemplateIndex(t *testing.T) {
+	tests := []struct {
+		template string
+		want     []string
+	}{
+		{"节点{index:02}", []string{"节点01", "节点02", "节点03", "节点04"}},
+		{"{region}-{index}", []string{"香港-1", "日本-1", "香港-2", "其他-1"}},
+		{"{flag}{index:02}", []string{"🇭🇰01", "🇯🇵01", "🇭🇰02", "01"}},
+	}
+	for _, tt := range tests {
+		r, err := New(config.RenameConfig{Template: tt.template})
+		if err != nil {
+			t.Fatal(err)
+		}
+		nodes := []*node.Node{
+			{Protocol: node.ProtocolTrojan, Name: "香港 A"},
+			{Protocol: node.ProtocolTrojan, Name: "JP B"},
+			{Protocol: node.ProtocolTrojan, Name: "HK C"},
+			{Protocol: node.ProtocolTrojan, Name: "unknown"},
+		}
+		r.Apply(nodes, nil)
+		if got := names(nodes); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("模板 %q 得到 %q，期望 %q", tt.template, got, tt.want)
+		}
+	}
+}
+
+func TestUniquifyReserved(t *testing.T) {
+	nodes := []*node.Node{
+		{Protocol: node.ProtocolTrojan, Name: "A"},
+		{Protocol: node.ProtocolTrojan, Name: "A"},
+		{Protocol: node.ProtocolTrojan, Name: "DIRECT"},
+		{Protocol: node.ProtocolUnknown, Raw: "foo://bar"},
+		{Protocol: node.ProtocolTrojan, Name: "A 2"},
+	}
+	Uniquify(nodes, []string{"DIRECT", "REJECT"})
+	want := []string{"A", "A 2", "DIRECT 2", "", "A 2 2"}
+	if got := names(nodes); !reflect.DeepEqual(got, want) {
+		t.Errorf("得到 %q，期望 %q", got, want)
+	}
+}
+
+func TestApplySourceWithTemplate(t *testing.T) {
+	tests := []struct {
+		cfg  config.RenameConfig
+		want []string
+	}{
+		{config.RenameConfig{SourcePrefix: true}, []string{"香港 A", "a.example.com JP B"}},
+		{config.RenameConfig{Template: "{region}-{index}", SourcePrefix: true}, []string{"香港-1", "a.example.com 日本-1"}},
+		{config.RenameConfig{Template: "{region}-{index}", SourceSuffix: true}, []string{"香港-1", "日本-1 a.example.com"}},
+		// 模板已包含 {source} 时不重复添加
+		{config.RenameConfig{Template: "{source}|{region}", SourcePrefix: true, SourceSuffix: true}, []string{"|香港", "a.example.com|日本"}},
+	}
+	for _, tt := range tests {
+		r, err := New(tt.cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		nodes := []*node.Node{
+			{Protocol: node.ProtocolTrojan, Name: "香港 A", Source: node.SourceMain},
+			{Protocol: node.ProtocolTrojan, Name: "JP B", Source: "https://a.example.com/sub"},
+		}
+		r.Apply(nodes, nil)
+		if got := names(nodes); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%+v 得到 %q，期望 %q", tt.cfg, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package service
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"sublinks/config"
+)
+
+// cacheEntry 单个订阅源的缓存内容
+type cacheEntry struct {
+	// mutex 保证同一订阅源同时只有一个请求在获取
+	mutex sync.Mutex
+
+	content      string
+	etag         string
+	lastModified string
+	userInfo     *UserInfo
+	// fetched 最近一次成功获取或确认未修改的时间，零值表示尚未获取成功
+	fetched    time.Time
+	refreshing bool
+	// err 最近一次请求上游失败的原因，成功后清空
+	err error
+	// failed 最近一次请求上游失败的时间，成功后清空
+	failed time.Time
+}
+
+// failureRetry 请求上游失败后，在该时间内不再重新请求，直接返回旧内容或错误
+const failureRetry = time.Minute
+
+// cachedSource Get 返回的订阅源内容
+type cachedSource struct {
+	content  string
+	userInfo *UserInfo
+	// stale 上游请求失败，内容为上一次成功获取的结果
+	stale bool
+}
+
+// SourceCache 按订阅地址缓存上游内容：有效期内直接返回缓存；过期后先返回旧内容并在后台刷新；
+// 上游请求失败时继续使用最近一次成功获取的内容，并在 retry 时间内不再请求该上游。
+// 没有定时刷新，过期的缓存只在下一次 Get 时刷新
+type SourceCache struct {
+	ttl     time.Duration
+	retry   time.Duration
+	fetcher *Fetcher
+
+	mutex   sync.Mutex
+	entries map[string]*cacheEntry
+}
+
+// NewSourceCache 创建订阅缓存，ttl 不大于0时每次请求都重新获取，但仍会在失败时返回旧内容
+func NewSourceCache(ttl time.Duration, fetcher *Fetcher) *SourceCache {
+	return &SourceCache{
+		ttl:     ttl,
+		retry:   failureRetry,
+		fetcher: fetcher,
+		entries: make(map[string]*cacheEntry),
+	}
+}
+
+// Get 返回订阅内容。上游请求失败但有缓存时返回缓存内容，此时 stale 为 true，err 为失败原因；
+// 没有可用内容时 stale 为 false 并返回错误。失败后 retry 时间内的请求直接使用上次的结果
+func (c *SourceCache) Get(source config.Source) (cachedSource, error) {
+	entry := c.entry(source.URL)
+
+	entry.mutex.Lock()
+	defer entry.mutex.Unlock()
+
+	ttl := c.ttlFor(source)
+	switch {
+	case !entry.failed.IsZero() && time.Since(entry.failed) < c.retry:
+		// 最近请求失败，不再请求上游，返回旧内容或上次的错误
+	case entry.fetched.IsZero() || ttl <= 0:
+		c.update(source, entry)
+	case time.Since(entry.fetched) >= ttl && !entry.refreshing:
+		// 已过期，返回旧内容并在后台刷新
+		entry.refreshing = true
+		go c.refresh(source, entry)
+	}
+	if entry.fetched.IsZero() {
+		return cachedSource{}, entry.err
+	}
+
+	return cachedSource{
+		content:  entry.content,
+		userInfo: entry.userInfo,
+		stale:    entry.err != nil,
+	}, entry.err
+}
+
+// ttlFor 返回订阅源的缓存有效期，订阅源设置了 refresh_interval 时覆盖全局设置
+func (c *SourceCache) ttlFor(source config.Source) time.Duration {
+	if source.RefreshInterval > 0 {
+		return time.Duration(source.RefreshInterval) * time.Second
+	}
+	return c.ttl
+}
+
+// Retain 删除不在列表中的订阅源缓存
+func (c *SourceCache) Retain(urls []string) {
+	keep := make(map[string]bool, len(urls))
+	for _, url := range urls {
+		keep[url] = true
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for url := range c.entries {
+		if !keep[url] {
+			delete(c.entries, url)
+		}
+	}
+}
+
+func (c *SourceCache) entry(url string) *cacheEntry {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	entry, ok := c.entries[url]
+	if !ok {
+		entry = &cacheEntry{}
+		c.entries[url] = entry
+	}
+	return entry
+}
+
+// refresh 后台刷新过期的缓存
+func (c *SourceCache) refresh(source config.Source, entry *cacheEntry) {
+	entry.mutex.Lock()
+	defer entry.mutex.Unlock()
+
+	entry.refreshing = false
+	if err := c.update(source, entry); err != nil {
+		log.Printf("后台刷新订阅 %s 失败，继续使用缓存内容: %v", source.Name, err)
+	}
+}
+
+// update 使用 ETag/Last-Modified 发送条件请求并记录结果，调用方需持有 entry.mutex。
+// 失败时保留已缓存的内容
+func (c *SourceCache) update(source config.Source, entry *cacheEntry) error {
+	result, err := c.fetcher.Fetch(source, entry.etag, entry.lastModified)
+	entry.err = err
+	if err != nil {
+		entry.failed = time.Now()
+		return err
+	}
+	entry.failed = time.Time{}
+
+	if !result.notModified {
+		entry.content = result.content
+		entry.etag = result.etag
+		entry.lastModified = result.lastModified
+	}
+	// 304 响应不一定带有流量信息，此时沿用之前的结果
+	if !result.notModified || result.userInfo != nil {
+		entry.userInfo = result.userInfo
+	}
+	entry.fetched = time.Now()
+	return nil
+}
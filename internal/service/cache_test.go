@@ -0,0 +1,63 @@
+package service
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"sublinks/config"
+)
+
+func TestSourceCacheBacksOffAfterFailure(t *testing.T) {
+	var hits atomic.Int32
+	var fail atomic.Bool
+	fail.Store(true)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		if fail.Load() {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("trojan://secret@example.com:443#A"))
+	}))
+	defer server.Close()
+
+	cache := NewSourceCache(0, NewFetcher(config.FetchConfig{}))
+	source := config.Source{Name: "test", URL: server.URL}
+
+	// 从未成功的订阅源在退避期内不再请求上游
+	for i := 0; i < 3; i++ {
+		if _, err := cache.Get(source); err == nil {
+			t.Fatal("期望返回错误")
+		}
+	}
+	if got := hits.Load(); got != 1 {
+		t.Errorf("请求上游 %d 次，期望 1 次", got)
+	}
+
+	// 退避期结束后重新请求
+	fail.Store(false)
+	cache.retry = 0
+	got, err := cache.Get(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.content == "" || got.stale {
+		t.Errorf("得到 %+v，期望最新内容", got)
+	}
+
+	// 成功过的订阅源失败后在退避期内返回旧内容
+	fail.Store(true)
+	cache.Get(source)
+	cache.retry = time.Hour
+	before := hits.Load()
+	got, err = cache.Get(source)
+	if err == nil || !got.stale || got.content == "" {
+		t.Errorf("得到 %+v, %v，期望旧内容和错误", got, err)
+	}
+	if hits.Load() != before {
+		t.Error("退避期内请求了上游")
+	}
+}
@@ -0,0 +1,390 @@
+package service
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"sublinks/internal/acl"
+	"sublinks/internal/node"
+)
+
+// clashConfig Clash/Mihomo 配置文件
+type clashConfig struct {
+	Port               int               `yaml:"port"`
+	SocksPort          int               `yaml:"socks-port"`
+	AllowLan           bool              `yaml:"allow-lan"`
+	Mode               string            `yaml:"mode"`
+	LogLevel           string            `yaml:"log-level"`
+	ExternalController string            `yaml:"external-controller"`
+	Proxies            []clashProxy      `yaml:"proxies"`
+	ProxyGroups        []clashProxyGroup `yaml:"proxy-groups"`
+	Rules              []string          `yaml:"rules"`
+}
+
+// clashProxy Clash 代理节点
+type clashProxy struct {
+	Name   string `yaml:"name"`
+	Type   string `yaml:"type"`
+	Server string `yaml:"server"`
+	Port   int    `yaml:"port"`
+	Ports  string `yaml:"ports,omitempty"`
+
+	UUID     string `yaml:"uuid,omitempty"`
+	AlterID  *int   `yaml:"alterId,omitempty"`
+	Cipher   string `yaml:"cipher,omitempty"`
+	Password string `yaml:"password,omitempty"`
+	Flow     string `yaml:"flow,omitempty"`
+	UDP      bool   `yaml:"udp,omitempty"`
+
+	TLS               bool              `yaml:"tls,omitempty"`
+	ServerName        string            `yaml:"servername,omitempty"`
+	SNI               string            `yaml:"sni,omitempty"`
+	ALPN              []string          `yaml:"alpn,omitempty"`
+	ClientFingerprint string            `yaml:"client-fingerprint,omitempty"`
+	SkipCertVerify    bool              `yaml:"skip-cert-verify,omitempty"`
+	RealityOpts       *clashRealityOpts `yaml:"reality-opts,omitempty"`
+
+	Network  string         `yaml:"network,omitempty"`
+	WSOpts   *clashWSOpts   `yaml:"ws-opts,omitempty"`
+	GRPCOpts *clashGRPCOpts `yaml:"grpc-opts,omitempty"`
+	H2Opts   *clashH2Opts   `yaml:"h2-opts,omitempty"`
+	HTTPOpts *clashHTTPOpts `yaml:"http-opts,omitempty"`
+
+	Plugin     string                 `yaml:"plugin,omitempty"`
+	PluginOpts map[string]interface{} `yaml:"plugin-opts,omitempty"`
+
+	Protocol      string `yaml:"protocol,omitempty"`
+	ProtocolParam string `yaml:"protocol-param,omitempty"`
+	Obfs          string `yaml:"obfs,omitempty"`
+	ObfsParam     string `yaml:"obfs-param,omitempty"`
+	ObfsPassword  string `yaml:"obfs-password,omitempty"`
+
+	Up                   string `yaml:"up,omitempty"`
+	Down                 string `yaml:"down,omitempty"`
+	CongestionController string `yaml:"congestion-controller,omitempty"`
+	UDPRelayMode         string `yaml:"udp-relay-mode,omitempty"`
+}
+
+type clashRealityOpts struct {
+	PublicKey string `yaml:"public-key"`
+	ShortID   string `yaml:"short-id,omitempty"`
+}
+
+type clashWSOpts struct {
+	Path             string            `yaml:"path,omitempty"`
+	Headers          map[string]string `yaml:"headers,omitempty"`
+	V2rayHTTPUpgrade bool              `yaml:"v2ray-http-upgrade,omitempty"`
+}
+
+type clashGRPCOpts struct {
+	ServiceName string `yaml:"grpc-service-name"`
+}
+
+type clashH2Opts struct {
+	Host []string `yaml:"host,omitempty"`
+	Path string   `yaml:"path,omitempty"`
+}
+
+type clashHTTPOpts struct {
+	Path    []string            `yaml:"path,omitempty"`
+	Headers map[string][]string `yaml:"headers,omitempty"`
+}
+
+// clashProxyGroup Clash 代理组
+type clashProxyGroup struct {
+	Name      string   `yaml:"name"`
+	Type      string   `yaml:"type"`
+	Proxies   []string `yaml:"proxies"`
+	URL       string   `yaml:"url,omitempty"`
+	Interval  int      `yaml:"interval,omitempty"`
+	Tolerance int      `yaml:"tolerance,omitempty"`
+}
+
+// buildClashConfig 根据节点列表构建Clash配置，无法转换的节点会被跳过
+func (c *Converter) buildClashConfig(nodes []*node.Node, t *acl.Template) (string, error) {
+	// 基础配置
+	config := clashConfig{
+		Port:               7890,
+		SocksPort:          7891,
+		AllowLan:           true,
+		Mode:               "rule",
+		LogLevel:           "info",
+		ExternalController: "127.0.0.1:9090",
+	}
+
+	// 添加节点
+	var nodeNames []string
+	skipped := 0
+	for i, n := range nodes {
+		nodeName := proxyName(n, i)
+
+		proxy, ok := newClashProxy(n, nodeName)
+		if !ok {
+			skipped++
+			continue
+		}
+
+		config.Proxies = append(config.Proxies, proxy)
+		nodeNames = append(nodeNames, nodeName)
+	}
+
+	if skipped > 0 {
+		log.Printf("Clash配置跳过 %d 个不支持的节点", skipped)
+	}
+	if len(nodeNames) == 0 {
+		return "", ErrNoNodes
+	}
+	log.Printf("生成Clash配置，共 %d 个节点", len(nodeNames))
+
+	// 添加代理组和规则
+	layout := c.layout(t, nodeNames)
+	for _, g := range layout.Groups {
+		config.ProxyGroups = append(config.ProxyGroups, clashProxyGroup{
+			Name:      g.Name,
+			Type:      g.Type,
+			Proxies:   g.Proxies,
+			URL:       g.URL,
+			Interval:  g.Interval,
+			Tolerance: g.Tolerance,
+		})
+	}
+	for _, r := range layout.Rules {
+		if rule, ok := clashRule(r); ok {
+			config.Rules = append(config.Rules, rule)
+		}
+	}
+
+	return marshalClashConfig(&config)
+}
+
+// marshalClashConfig 序列化配置，缩进为两个空格
+func marshalClashConfig(config *clashConfig) (string, error) {
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(config); err != nil {
+		return "", fmt.Errorf("生成Clash配置失败: %w", err)
+	}
+	if err := enc.Close(); err != nil {
+		return "", fmt.Errorf("生成Clash配置失败: %w", err)
+	}
+	return buf.String(), nil
+}
+
+// clashRuleTypes Clash/Mihomo 支持的规则类型，包含其他类型（如 USER-AGENT、URL-REGEX）时整个配置无法加载
+var clashRuleTypes = map[string]bool{
+	"DOMAIN":             true,
+	"DOMAIN-SUFFIX":      true,
+	"DOMAIN-KEYWORD":     true,
+	"DOMAIN-REGEX":       true,
+	"GEOSITE":            true,
+	"GEOIP":              true,
+	"IP-CIDR":            true,
+	"IP-CIDR6":           true,
+	"IP-SUFFIX":          true,
+	"IP-ASN":             true,
+	"SRC-GEOIP":          true,
+	"SRC-IP-CIDR":        true,
+	"SRC-IP-SUFFIX":      true,
+	"SRC-IP-ASN":         true,
+	"DST-PORT":           true,
+	"SRC-PORT":           true,
+	"IN-PORT":            true,
+	"IN-TYPE":            true,
+	"PROCESS-NAME":       true,
+	"PROCESS-NAME-REGEX": true,
+	"PROCESS-PATH":       true,
+	"PROCESS-PATH-REGEX": true,
+	"NETWORK":            true,
+	"UID":                true,
+}
+
+// clashRule 生成单条Clash规则，不支持的规则类型返回false
+func clashRule(r Rule) (string, bool) {
+	if r.Type == "MATCH" {
+		return "MATCH," + r.Target, true
+	}
+	if !clashRuleTypes[r.Type] {
+		return "", false
+	}
+	rule := r.Type + "," + r.Value + "," + r.Target
+	if r.NoResolve {
+		rule += ",no-resolve"
+	}
+	return rule, true
+}
+
+// newClashProxy 生成单个节点的Clash代理配置
+func newClashProxy(n *node.Node, name string) (clashProxy, bool) {
+	p := clashProxy{
+		Name:   name,
+		Type:   string(n.Protocol),
+		Server: n.Server,
+		Port:   n.Port,
+	}
+
+	switch n.Protocol {
+	case node.ProtocolVMess:
+		alterID := n.AlterID
+		p.UUID = n.UUID
+		p.AlterID = &alterID
+		p.Cipher = n.Cipher
+		p.UDP = true
+		if n.TLS.Enabled {
+			p.TLS = true
+			setClashTLS(&p, n.TLS, false)
+		}
+		setClashTransport(&p, n.Transport)
+	case node.ProtocolVLESS:
+		p.UUID = n.UUID
+		p.Flow = n.Flow
+		p.UDP = true
+		if n.TLS.Enabled {
+			p.TLS = true
+			setClashTLS(&p, n.TLS, false)
+		}
+		if reality := n.TLS.Reality; reality != nil {
+			if p.ClientFingerprint == "" {
+				p.ClientFingerprint = "chrome"
+			}
+			p.RealityOpts = &clashRealityOpts{
+				PublicKey: reality.PublicKey,
+				ShortID:   reality.ShortID,
+			}
+		}
+		setClashTransport(&p, n.Transport)
+	case node.ProtocolShadowsocks:
+		p.Cipher = n.Cipher
+		p.Password = n.Password
+		p.UDP = true
+		if n.Plugin != "" && !setClashPlugin(&p, n) {
+			return p, false
+		}
+	case node.ProtocolSSR:
+		p.Cipher = n.Cipher
+		p.Password = n.Password
+		p.Protocol = n.SSRProtocol
+		p.ProtocolParam = n.SSRProtocolParam
+		p.Obfs = n.Obfs
+		p.ObfsParam = n.ObfsParam
+	case node.ProtocolTrojan:
+		p.Password = n.Password
+		p.UDP = true
+		setClashTLS(&p, n.TLS, true)
+		setClashTransport(&p, n.Transport)
+	case node.ProtocolHysteria2:
+		p.Password = n.Password
+		p.Ports = n.Ports
+		if n.UpMbps > 0 {
+			p.Up = strconv.Itoa(n.UpMbps)
+		}
+		if n.DownMbps > 0 {
+			p.Down = strconv.Itoa(n.DownMbps)
+		}
+		p.Obfs = n.Obfs
+		p.ObfsPassword = n.ObfsPassword
+		setClashTLS(&p, n.TLS, true)
+	case node.ProtocolTUIC:
+		p.UUID = n.UUID
+		p.Password = n.Password
+		p.CongestionController = n.CongestionControl
+		p.UDPRelayMode = n.UDPRelayMode
+		setClashTLS(&p, n.TLS, true)
+	default:
+		return p, false
+	}
+
+	return p, true
+}
+
+// setClashPlugin 将 SIP003 插件转换为Clash的 plugin/plugin-opts 字段
+func setClashPlugin(p *clashProxy, n *node.Node) bool {
+	opts := n.PluginOptions()
+	switch n.Plugin {
+	case "obfs-local", "simple-obfs", "obfs":
+		p.Plugin = "obfs"
+		p.PluginOpts = map[string]interface{}{"mode": opts["obfs"]}
+		if host := opts["obfs-host"]; host != "" {
+			p.PluginOpts["host"] = host
+		}
+		return true
+	case "v2ray-plugin":
+		mode := opts["mode"]
+		if mode == "" {
+			mode = "websocket"
+		}
+		p.Plugin = "v2ray-plugin"
+		p.PluginOpts = map[string]interface{}{"mode": mode}
+		if _, ok := opts["tls"]; ok {
+			p.PluginOpts["tls"] = true
+		}
+		if host := opts["host"]; host != "" {
+			p.PluginOpts["host"] = host
+		}
+		if path := opts["path"]; path != "" {
+			p.PluginOpts["path"] = path
+		}
+		if _, ok := opts["mux"]; ok {
+			p.PluginOpts["mux"] = true
+		}
+		return true
+	}
+	return false
+}
+
+// setClashTLS 设置TLS相关字段，useSNI 决定使用 sni 还是 servername 字段
+func setClashTLS(p *clashProxy, t node.TLS, useSNI bool) {
+	if useSNI {
+		p.SNI = t.SNI
+	} else {
+		p.ServerName = t.SNI
+	}
+	p.ALPN = t.ALPN
+	p.ClientFingerprint = t.Fingerprint
+	p.SkipCertVerify = t.Insecure
+}
+
+// setClashTransport 设置传输层相关字段
+func setClashTransport(p *clashProxy, t node.Transport) {
+	switch t.Type {
+	case "ws", "httpupgrade":
+		p.Network = "ws"
+		p.WSOpts = &clashWSOpts{
+			Path:             t.Path,
+			V2rayHTTPUpgrade: t.Type == "httpupgrade",
+		}
+		if t.Host != "" {
+			p.WSOpts.Headers = map[string]string{"Host": t.Host}
+		}
+	case "grpc":
+		p.Network = "grpc"
+		p.GRPCOpts = &clashGRPCOpts{ServiceName: t.ServiceName}
+	case "h2":
+		p.Network = "h2"
+		p.H2Opts = &clashH2Opts{Path: t.Path}
+		for _, host := range strings.Split(t.Host, ",") {
+			if host = strings.TrimSpace(host); host != "" {
+				p.H2Opts.Host = append(p.H2Opts.Host, host)
+			}
+		}
+	case "tcp", "":
+		if t.HeaderType != "http" {
+			return
+		}
+		p.Network = "http"
+		p.HTTPOpts = &clashHTTPOpts{}
+		if t.Path != "" {
+			p.HTTPOpts.Path = []string{t.Path}
+		}
+		if t.Host != "" {
+			p.HTTPOpts.Headers = map[string][]string{"Host": {t.Host}}
+		}
+	default:
+		p.Network = t.Type
+	}
+}
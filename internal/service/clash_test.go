@@ -0,0 +1,230 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+
+	"sublinks/internal/node"
+)
+
+func intPtr(v int) *int { return &v }
+
+func TestClashConfigRoundTrip(t *testing.T) {
+	tests := []struct {
+		node node.Node
+		want clashProxy
+	}{
+		{
+			node: node.Node{
+				Protocol: node.ProtocolVMess, Name: "vmess-ws", Server: "vm.example.com", Port: 443,
+				UUID: "uuid-1", Cipher: "auto", AlterID: 0,
+				Transport: node.Transport{Type: "ws", Host: "cdn.example.com", Path: "/ray"},
+				TLS:       node.TLS{Enabled: true, SNI: "vm.example.com", ALPN: []string{"h2", "http/1.1"}, Fingerprint: "chrome", Insecure: true},
+			},
+			want: clashProxy{
+				Name: "vmess-ws", Type: "vmess", Server: "vm.example.com", Port: 443,
+				UUID: "uuid-1", AlterID: intPtr(0), Cipher: "auto", UDP: true,
+				TLS: true, ServerName: "vm.example.com", ALPN: []string{"h2", "http/1.1"}, ClientFingerprint: "chrome", SkipCertVerify: true,
+				Network: "ws", WSOpts: &clashWSOpts{Path: "/ray", Headers: map[string]string{"Host": "cdn.example.com"}},
+			},
+		},
+		{
+			node: node.Node{
+				Protocol: node.ProtocolVMess, Name: "vmess-h2", Server: "h2.example.com", Port: 443,
+				UUID: "uuid-2", Cipher: "aes-128-gcm", AlterID: 64,
+				Transport: node.Transport{Type: "h2", Host: "a.example.com, b.example.com", Path: "/h2"},
+				TLS:       node.TLS{Enabled: true},
+			},
+			want: clashProxy{
+				Name: "vmess-h2", Type: "vmess", Server: "h2.example.com", Port: 443,
+				UUID: "uuid-2", AlterID: intPtr(64), Cipher: "aes-128-gcm", UDP: true, TLS: true,
+				Network: "h2", H2Opts: &clashH2Opts{Host: []string{"a.example.com", "b.example.com"}, Path: "/h2"},
+			},
+		},
+		{
+			node: node.Node{
+				Protocol: node.ProtocolVMess, Name: "vmess-http", Server: "1.2.3.4", Port: 80,
+				UUID: "uuid-3", Cipher: "auto",
+				Transport: node.Transport{Type: "tcp", HeaderType: "http", Host: "www.example.com", Path: "/"},
+			},
+			want: clashProxy{
+				Name: "vmess-http", Type: "vmess", Server: "1.2.3.4", Port: 80,
+				UUID: "uuid-3", AlterID: intPtr(0), Cipher: "auto", UDP: true,
+				Network: "http", HTTPOpts: &clashHTTPOpts{Path: []string{"/"}, Headers: map[string][]string{"Host": {"www.example.com"}}},
+			},
+		},
+		{
+			node: node.Node{
+				Protocol: node.ProtocolVLESS, Name: "vless-reality", Server: "re.example.com", Port: 443,
+				UUID: "uuid-4", Cipher: "none", Flow: "xtls-rprx-vision",
+				Transport: node.Transport{Type: "tcp"},
+				TLS: node.TLS{Enabled: true, SNI: "www.microsoft.com",
+					Reality: &node.Reality{PublicKey: "pbk-value", ShortID: "6ba85179e30d4fc2"}},
+			},
+			want: clashProxy{
+				Name: "vless-reality", Type: "vless", Server: "re.example.com", Port: 443,
+				UUID: "uuid-4", Flow: "xtls-rprx-vision", UDP: true,
+				TLS: true, ServerName: "www.microsoft.com", ClientFingerprint: "chrome",
+				RealityOpts: &clashRealityOpts{PublicKey: "pbk-value", ShortID: "6ba85179e30d4fc2"},
+			},
+		},
+		{
+			node: node.Node{
+				Protocol: node.ProtocolVLESS, Name: "vless-grpc", Server: "grpc.example.com", Port: 443,
+				UUID: "uuid-5", Cipher: "none",
+				Transport: node.Transport{Type: "grpc", ServiceName: "gsvc"},
+				TLS:       node.TLS{Enabled: true, SNI: "grpc.example.com"},
+			},
+			want: clashProxy{
+				Name: "vless-grpc", Type: "vless", Server: "grpc.example.com", Port: 443,
+				UUID: "uuid-5", UDP: true, TLS: true, ServerName: "grpc.example.com",
+				Network: "grpc", GRPCOpts: &clashGRPCOpts{ServiceName: "gsvc"},
+			},
+		},
+		{
+			node: node.Node{
+				Protocol: node.ProtocolVLESS, Name: "vless-httpupgrade", Server: "hu.example.com", Port: 80,
+				UUID: "uuid-6", Cipher: "none",
+				Transport: node.Transport{Type: "httpupgrade", Path: "/up"},
+			},
+			want: clashProxy{
+				Name: "vless-httpupgrade", Type: "vless", Server: "hu.example.com", Port: 80,
+				UUID: "uuid-6", UDP: true,
+				Network: "ws", WSOpts: &clashWSOpts{Path: "/up", V2rayHTTPUpgrade: true},
+			},
+		},
+		{
+			node: node.Node{
+				Protocol: node.ProtocolShadowsocks, Name: "ss-obfs", Server: "ss.example.com", Port: 8388,
+				Cipher: "aes-256-gcm", Password: "pass:word", Plugin: "obfs-local", PluginOpts: "obfs=http;obfs-host=bing.com",
+			},
+			want: clashProxy{
+				Name: "ss-obfs", Type: "ss", Server: "ss.example.com", Port: 8388,
+				Cipher: "aes-256-gcm", Password: "pass:word", UDP: true,
+				Plugin: "obfs", PluginOpts: map[string]interface{}{"mode": "http", "host": "bing.com"},
+			},
+		},
+		{
+			node: node.Node{
+				Protocol: node.ProtocolShadowsocks, Name: "ss-v2ray", Server: "ss2.example.com", Port: 443,
+				Cipher: "2022-blake3-aes-128-gcm", Password: "a2V5", Plugin: "v2ray-plugin", PluginOpts: "tls;host=ws.example.com;path=/ws;mux",
+			},
+			want: clashProxy{
+				Name: "ss-v2ray", Type: "ss", Server: "ss2.example.com", Port: 443,
+				Cipher: "2022-blake3-aes-128-gcm", Password: "a2V5", UDP: true,
+				Plugin: "v2ray-plugin", PluginOpts: map[string]interface{}{
+					"mode": "websocket", "tls": true, "host": "ws.example.com", "path": "/ws", "mux": true,
+				},
+			},
+		},
+		{
+			node: node.Node{
+				Protocol: node.ProtocolSSR, Name: "ssr", Server: "ssr.example.com", Port: 9000,
+				Cipher: "aes-256-cfb", Password: "ssrpass", SSRProtocol: "auth_aes128_md5", SSRProtocolParam: "1:abc",
+				Obfs: "tls1.2_ticket_auth", ObfsParam: "obfs.example.com",
+			},
+			want: clashProxy{
+				Name: "ssr", Type: "ssr", Server: "ssr.example.com", Port: 9000,
+				Cipher: "aes-256-cfb", Password: "ssrpass", Protocol: "auth_aes128_md5", ProtocolParam: "1:abc",
+				Obfs: "tls1.2_ticket_auth", ObfsParam: "obfs.example.com",
+			},
+		},
+		{
+			node: node.Node{
+				Protocol: node.ProtocolTrojan, Name: "trojan-ws", Server: "tj.example.com", Port: 443,
+				Password:  "#secret: yes",
+				Transport: node.Transport{Type: "ws", Host: "tj.example.com", Path: "/tj"},
+				TLS:       node.TLS{Enabled: true, SNI: "tj.example.com", Fingerprint: "firefox"},
+			},
+			want: clashProxy{
+				Name: "trojan-ws", Type: "trojan", Server: "tj.example.com", Port: 443,
+				Password: "#secret: yes", UDP: true, SNI: "tj.example.com", ClientFingerprint: "firefox",
+				Network: "ws", WSOpts: &clashWSOpts{Path: "/tj", Headers: map[string]string{"Host": "tj.example.com"}},
+			},
+		},
+		{
+			node: node.Node{
+				Protocol: node.ProtocolHysteria2, Name: "hy2", Server: "hy.example.com", Port: 20000,
+				Password: "hypass", Ports: "20000-30000", UpMbps: 50, DownMbps: 200,
+				Obfs: "salamander", ObfsPassword: "obfspass",
+				TLS: node.TLS{Enabled: true, SNI: "hy.example.com", ALPN: []string{"h3"}, Insecure: true},
+			},
+			want: clashProxy{
+				Name: "hy2", Type: "hysteria2", Server: "hy.example.com", Port: 20000, Ports: "20000-30000",
+				Password: "hypass", Up: "50", Down: "200", Obfs: "salamander", ObfsPassword: "obfspass",
+				SNI: "hy.example.com", ALPN: []string{"h3"}, SkipCertVerify: true,
+			},
+		},
+		{
+			node: node.Node{
+				Protocol: node.ProtocolTUIC, Name: "tuic", Server: "tuic.example.com", Port: 443,
+				UUID: "uuid-7", Password: "tuicpass", CongestionControl: "bbr", UDPRelayMode: "native",
+				TLS: node.TLS{Enabled: true, SNI: "tuic.example.com", ALPN: []string{"h3"}},
+			},
+			want: clashProxy{
+				Name: "tuic", Type: "tuic", Server: "tuic.example.com", Port: 443,
+				UUID: "uuid-7", Password: "tuicpass", CongestionController: "bbr", UDPRelayMode: "native",
+				SNI: "tuic.example.com", ALPN: []string{"h3"},
+			},
+		},
+	}
+
+	nodes := make([]*node.Node, len(tests))
+	for i := range tests {
+		nodes[i] = &tests[i].node
+	}
+
+	out, err := (&Converter{}).buildClashConfig(nodes, nil)
+	if err != nil {
+		t.Fatalf("buildClashConfig 失败: %v", err)
+	}
+
+	var parsed clashConfig
+	if err := yaml.Unmarshal([]byte(out), &parsed); err != nil {
+		t.Fatalf("解析生成的配置失败: %v\n%s", err, out)
+	}
+	if len(parsed.Proxies) != len(tests) {
+		t.Fatalf("节点数量为 %d，期望 %d", len(parsed.Proxies), len(tests))
+	}
+	for i, tt := range tests {
+		if got := parsed.Proxies[i]; !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("节点 %s\n得到 %+v\n期望 %+v", tt.want.Name, got, tt.want)
+		}
+	}
+
+	if len(parsed.ProxyGroups) == 0 || len(parsed.Rules) == 0 {
+		t.Errorf("缺少代理组或规则: %+v", parsed)
+	}
+}
+
+func TestClashConfigSkipsUnsupported(t *testing.T) {
+	nodes := []*node.Node{
+		node.NewUnknown("foo://bar", "test"),
+		{Protocol: node.ProtocolShadowsocks, Name: "ss-kcptun", Server: "a.com", Port: 1, Cipher: "aes-256-gcm", Password: "p", Plugin: "kcptun"},
+	}
+	if _, err := (&Converter{}).buildClashConfig(nodes, nil); err != ErrNoNodes {
+		t.Errorf("期望 ErrNoNodes，得到 %v", err)
+	}
+}
+
+func TestClashRuleSkipsUnsupportedTypes(t *testing.T) {
+	tests := []struct {
+		rule Rule
+		want string
+		ok   bool
+	}{
+		{Rule{Type: "DOMAIN-SUFFIX", Value: "google.com", Target: "Proxy"}, "DOMAIN-SUFFIX,google.com,Proxy", true},
+		{Rule{Type: "IP-CIDR", Value: "10.0.0.0/8", Target: "DIRECT", NoResolve: true}, "IP-CIDR,10.0.0.0/8,DIRECT,no-resolve", true},
+		{Rule{Type: "MATCH", Target: "Proxy"}, "MATCH,Proxy", true},
+		{Rule{Type: "USER-AGENT", Value: "Instagram*", Target: "Proxy"}, "", false},
+		{Rule{Type: "URL-REGEX", Value: "^https?://ad", Target: "REJECT"}, "", false},
+	}
+	for _, tt := range tests {
+		got, ok := clashRule(tt.rule)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("clashRule(%+v) = %q, %v，期望 %q, %v", tt.rule, got, ok, tt.want, tt.ok)
+		}
+	}
+}
@@ -2,13 +2,18 @@ package service
 
 import (
 	"encoding/base64"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
 	"net/url"
 	"strings"
+	"time"
+
+	"sublinks/config"
+	"sublinks/internal/acl"
+	"sublinks/internal/node"
 )
 
 type ConverterType string
@@ -17,62 +22,133 @@ const (
 	TypeV2ray   ConverterType = "v2ray"
 	TypeClash   ConverterType = "clash"
 	TypeSingBox ConverterType = "singbox"
+	TypeSurge   ConverterType = "surge"
+	TypeLoon    ConverterType = "loon"
+	TypeQuanX   ConverterType = "quanx"
+
+	// TypeRaw 明文分享链接列表，用于浏览器查看
+	TypeRaw ConverterType = "raw"
+)
+
+// targetAliases 客户端通过 target 参数或路径指定格式时可用的名称
+var targetAliases = map[string]ConverterType{
+	"v2ray":       TypeV2ray,
+	"v2rayn":      TypeV2ray,
+	"base64":      TypeV2ray,
+	"clash":       TypeClash,
+	"clashmeta":   TypeClash,
+	"mihomo":      TypeClash,
+	"meta":        TypeClash,
+	"singbox":     TypeSingBox,
+	"sing-box":    TypeSingBox,
+	"surge":       TypeSurge,
+	"loon":        TypeLoon,
+	"quanx":       TypeQuanX,
+	"quantumultx": TypeQuanX,
+	"qx":          TypeQuanX,
+	"raw":         TypeRaw,
+	"plain":       TypeRaw,
+}
+
+// ParseTarget 解析显式指定的输出格式
+func ParseTarget(target string) (ConverterType, bool) {
+	t, ok := targetAliases[strings.ToLower(strings.TrimSpace(target))]
+	return t, ok
+}
+
+// 默认代理组
+const (
+	groupSelect = "🚀 节点选择"
+	groupAuto   = "♻️ 自动选择"
+	testURL     = "https://www.gstatic.com/generate_204"
 )
 
+// ConvertOptions 单次转换的附加参数
+type ConvertOptions struct {
+	// SubscriptionURL 客户端访问的订阅地址，用于 Surge 托管配置
+	SubscriptionURL string
+}
+
 type Converter struct {
-	backend    string
-	configFile string
-	updateTime int
+	backend        string
+	configFile     string
+	updateTime     int
+	singboxVersion string
+	// remoteFallback 为true时，本地无法加载 ini 配置则改用远程转换服务
+	remoteFallback bool
+
+	templates    *acl.Loader
+	regionGroups config.RegionGroupConfig
 }
 
-func NewConverter(backend, configFile string, updateTime int) *Converter {
+func NewConverter(cfg *config.Config) *Converter {
 	return &Converter{
-		backend:    backend,
-		configFile: configFile,
-		updateTime: updateTime,
+		backend:        cfg.Subconverter,
+		configFile:     cfg.SubConfig,
+		updateTime:     cfg.SUBUpdateTime,
+		singboxVersion: cfg.SingBoxVersion,
+		remoteFallback: cfg.RemoteFallback,
+		templates:      acl.NewLoader(time.Duration(cfg.RulesetUpdateTime) * time.Hour),
+		regionGroups:   cfg.RegionGroups,
 	}
 }
 
-func (c *Converter) Convert(content string, targetType ConverterType) (string, error) {
-	if targetType == TypeV2ray {
-		return content, nil
-	}
-
-	// 尝试直接创建Clash配置
-	if targetType == TypeClash {
-		log.Printf("尝试直接生成Clash配置")
+// ErrNoNodes 表示没有可输出的节点
+var ErrNoNodes = errors.New("没有可用的节点")
 
-		// 解码base64以获取原始节点列表
-		decoded, err := base64.StdEncoding.DecodeString(content)
-		if err != nil {
-			log.Printf("Base64解码失败，尝试使用原始内容: %v", err)
-			decoded = []byte(content)
+// Convert 将节点列表转换为目标客户端格式
+func (c *Converter) Convert(nodes []*node.Node, targetType ConverterType, opts ConvertOptions) (string, error) {
+	if targetType == TypeV2ray || targetType == TypeRaw {
+		plain := c.Plain(nodes)
+		if plain == "" {
+			return "", ErrNoNodes
 		}
+		if targetType == TypeRaw {
+			return plain, nil
+		}
+		return base64.StdEncoding.EncodeToString([]byte(plain)), nil
+	}
 
-		// 解析节点列表
-		nodeList := strings.Split(string(decoded), "\n")
-		validNodes := make([]string, 0)
-
-		// 过滤有效节点
-		for _, node := range nodeList {
-			node = strings.TrimSpace(node)
-			if node != "" {
-				validNodes = append(validNodes, node)
-			}
+	// 加载 ini 配置中的代理组和规则
+	t, err := c.loadTemplate()
+	if err != nil {
+		if c.remoteFallback {
+			log.Printf("加载订阅转换配置失败，改用远程转换服务: %v", err)
+			return c.convertRemote(nodes, targetType)
 		}
+		log.Printf("加载订阅转换配置失败，使用默认代理组: %v", err)
+	}
+
+	switch targetType {
+	case TypeClash:
+		return c.buildClashConfig(nodes, t)
+	case TypeSingBox:
+		return c.buildSingBoxConfig(nodes, t)
+	case TypeSurge:
+		return c.buildSurgeConfig(nodes, t, opts.SubscriptionURL)
+	case TypeLoon:
+		return c.buildLoonConfig(nodes, t)
+	case TypeQuanX:
+		return c.buildQuanXConfig(nodes, t)
+	}
+	return "", fmt.Errorf("不支持的订阅格式: %s", targetType)
+}
 
-		// 如果有有效节点，创建Clash配置
-		if len(validNodes) > 0 {
-			log.Printf("找到 %d 个有效节点，生成Clash配置", len(validNodes))
-			clashConfig := c.buildClashConfig(validNodes)
-			return clashConfig, nil
+// convertRemote 使用远程转换服务生成配置，节点链接会发送给该服务
+func (c *Converter) convertRemote(nodes []*node.Node, targetType ConverterType) (string, error) {
+	uris := make([]string, 0, len(nodes))
+	for _, n := range nodes {
+		if n.Protocol != node.ProtocolUnknown {
+			uris = append(uris, n.URI())
 		}
 	}
+	if len(uris) == 0 {
+		return "", ErrNoNodes
+	}
 
-	// 如果直接创建失败，尝试使用转换服务
 	params := url.Values{}
 	params.Set("target", string(targetType))
-	params.Set("url", content)
+	params.Set("url", strings.Join(uris, "|"))
 	params.Set("insert", "false")
 	params.Set("config", c.configFile)
 	params.Set("emoji", "true")
@@ -85,15 +161,12 @@ func (c *Converter) Convert(content string, targetType ConverterType) (string, e
 
 	convertURL := fmt.Sprintf("https://%s/sub?%s", c.backend, params.Encode())
 
-	log.Printf("转换请求URL: %s", convertURL)
-	log.Printf("转换目标类型: %s", targetType)
+	// 请求地址包含节点凭据，只记录转换服务地址
+	log.Printf("使用远程转换服务: %s, 目标类型: %s", c.backend, targetType)
 
 	resp, err := http.Get(convertURL)
 	if err != nil {
 		log.Printf("转换请求失败: %v", err)
-		if targetType == TypeClash {
-			return c.generateDefaultClashConfig(), nil
-		}
 		return "", fmt.Errorf("转换请求失败: %w", err)
 	}
 	defer resp.Body.Close()
@@ -101,243 +174,91 @@ func (c *Converter) Convert(content string, targetType ConverterType) (string, e
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
 		log.Printf("转换服务返回错误状态码: %d, 响应: %s", resp.StatusCode, string(body))
-		if targetType == TypeClash {
-			log.Printf("Clash转换失败，返回默认配置")
-			return c.generateDefaultClashConfig(), nil
-		}
 		return "", fmt.Errorf("转换服务返回错误状态码: %d", resp.StatusCode)
 	}
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
 		log.Printf("读取转换结果失败: %v", err)
-		if targetType == TypeClash {
-			return c.generateDefaultClashConfig(), nil
-		}
 		return "", fmt.Errorf("读取转换结果失败: %w", err)
 	}
 
-	if len(body) < 10 && targetType == TypeClash {
-		log.Printf("转换结果内容过短，可能无效，返回默认配置")
-		return c.generateDefaultClashConfig(), nil
+	if len(body) < 10 {
+		return "", fmt.Errorf("转换结果内容过短: %d字节", len(body))
 	}
 
 	return string(body), nil
 }
 
-// buildClashConfig 根据节点列表构建Clash配置
-func (c *Converter) buildClashConfig(nodes []string) string {
-	// 提取节点名称
-	var nodeNames []string
-
-	// 基础配置
-	config := []string{
-		"port: 7890",
-		"socks-port: 7891",
-		"allow-lan: true",
-		"mode: Rule",
-		"log-level: info",
-		"external-controller: 127.0.0.1:9090",
-		"proxies:",
-	}
-
-	// 解析并添加节点
-	for i, node := range nodes {
-		nodeConfig := ""
-		nodeName := fmt.Sprintf("Node-%d", i+1)
-
-		if strings.HasPrefix(node, "vmess://") {
-			// 处理VMess节点
-			vmessURL := strings.TrimPrefix(node, "vmess://")
-			decodedBytes, err := base64.StdEncoding.DecodeString(vmessURL)
-			if err == nil {
-				var vmessInfo map[string]interface{}
-				if err := json.Unmarshal(decodedBytes, &vmessInfo); err == nil {
-					// 提取节点信息
-					if name, ok := vmessInfo["ps"].(string); ok && name != "" {
-						nodeName = name
-					}
-
-					// 构建VMess节点配置
-					server, _ := vmessInfo["add"].(string)
-					port, _ := vmessInfo["port"].(float64)
-					id, _ := vmessInfo["id"].(string)
-					aid, _ := vmessInfo["aid"].(float64)
-					network, _ := vmessInfo["net"].(string)
-					tls, _ := vmessInfo["tls"].(string)
-
-					nodeConfig = fmt.Sprintf(`  - name: "%s"
-    type: vmess
-    server: %s
-    port: %d
-    uuid: %s
-    alterId: %d
-    cipher: auto
-    network: %s
-    tls: %t`,
-						nodeName,
-						server,
-						int(port),
-						id,
-						int(aid),
-						network,
-						tls == "tls")
-
-					config = append(config, nodeConfig)
-					nodeNames = append(nodeNames, nodeName)
-					continue
-				}
-			}
-		} else if strings.HasPrefix(node, "ss://") {
-			// 处理Shadowsocks节点
-			ssURL := strings.TrimPrefix(node, "ss://")
-			parts := strings.Split(ssURL, "@")
-			if len(parts) >= 2 {
-				methodAndPassword, err := base64.StdEncoding.DecodeString(parts[0])
-				if err == nil {
-					methodAndPasswordParts := strings.Split(string(methodAndPassword), ":")
-					if len(methodAndPasswordParts) >= 2 {
-						method := methodAndPasswordParts[0]
-						password := methodAndPasswordParts[1]
-
-						serverAndPort := parts[1]
-						if hashIndex := strings.Index(serverAndPort, "#"); hashIndex > 0 {
-							// 如果有名称标识，提取名称
-							nodeName = serverAndPort[hashIndex+1:]
-							serverAndPort = serverAndPort[:hashIndex]
-						}
-
-						serverParts := strings.Split(serverAndPort, ":")
-						if len(serverParts) >= 2 {
-							server := serverParts[0]
-							port := serverParts[1]
-
-							nodeConfig = fmt.Sprintf(`  - name: "%s"
-    type: ss
-    server: %s
-    port: %s
-    cipher: %s
-    password: %s`,
-								nodeName,
-								server,
-								port,
-								method,
-								password)
-
-							config = append(config, nodeConfig)
-							nodeNames = append(nodeNames, nodeName)
-							continue
-						}
-					}
-				}
-			}
-		} else if strings.HasPrefix(node, "trojan://") {
-			// 处理Trojan节点
-			trojanURL := strings.TrimPrefix(node, "trojan://")
-			// 解析trojan URL
-			uri, err := url.Parse("trojan://" + trojanURL)
-			if err == nil {
-				password := uri.User.String()
-				host := uri.Host
-
-				// 提取端口
-				hostParts := strings.Split(host, ":")
-				server := hostParts[0]
-				port := "443"
-				if len(hostParts) > 1 {
-					port = hostParts[1]
-				}
-
-				// 提取节点名称
-				if uri.Fragment != "" {
-					nodeName = uri.Fragment
-				}
-
-				nodeConfig = fmt.Sprintf(`  - name: "%s"
-    type: trojan
-    server: %s
-    port: %s
-    password: %s
-    sni: %s`,
-					nodeName,
-					server,
-					port,
-					password,
-					server)
-
-				config = append(config, nodeConfig)
-				nodeNames = append(nodeNames, nodeName)
-				continue
-			}
+// Plain 将节点列表输出为换行分隔的分享链接
+func (c *Converter) Plain(nodes []*node.Node) string {
+	lines := make([]string, 0, len(nodes))
+	for _, n := range nodes {
+		if uri := n.URI(); uri != "" {
+			lines = append(lines, uri)
 		}
-
-		// 如果无法解析，使用通用配置
-		nodeConfig = fmt.Sprintf(`  - name: "%s"
-    type: http
-    server: example.com
-    port: 443`, nodeName)
-
-		config = append(config, nodeConfig)
-		nodeNames = append(nodeNames, nodeName)
 	}
+	return strings.Join(lines, "\n")
+}
 
-	// 如果没有成功解析任何节点，返回默认配置
-	if len(nodeNames) == 0 {
-		return c.generateDefaultClashConfig()
+// proxyName 返回节点名称，缺失时按序号生成
+func proxyName(n *node.Node, index int) string {
+	if n.Name != "" {
+		return n.Name
 	}
+	return fmt.Sprintf("Node-%d", index+1)
+}
 
-	// 添加代理组
-	config = append(config, "proxy-groups:")
-	config = append(config, "  - name: 🚀 节点选择")
-	config = append(config, "    type: select")
-	config = append(config, "    proxies:")
-
-	// 添加所有节点到代理组
-	for _, name := range nodeNames {
-		config = append(config, fmt.Sprintf("      - %s", name))
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
 	}
+	return ""
+}
 
-	// 添加DIRECT选项
-	config = append(config, "      - DIRECT")
+// lineProxyName 去除名称中会破坏 Surge/Loon/QuanX 行格式的字符
+func lineProxyName(name string) string {
+	return strings.Join(strings.Fields(strings.NewReplacer(",", " ", "=", " ").Replace(name)), " ")
+}
 
-	// 添加规则
-	config = append(config, "rules:")
-	config = append(config, "  - MATCH,🚀 节点选择")
+// lineNames 记录 Surge/Loon/QuanX 中已使用的代理名称。清理字符后不同的名称可能变得相同，
+// 如 "A,B" 和 "A B"，此时依次添加 " 2"、" 3" 等后缀
+type lineNames map[string]bool
 
-	return strings.Join(config, "\n")
+// newLineNames 创建代理名称记录，内置策略名称视为已占用
+func newLineNames() lineNames {
+	used := make(lineNames, len(builtinPolicies))
+	for _, name := range builtinPolicies {
+		used[name] = true
+	}
+	return used
 }
 
-func (c *Converter) generateDefaultClashConfig() string {
-	return `
-port: 7890
-socks-port: 7891
-allow-lan: true
-mode: Rule
-log-level: info
-external-controller: 127.0.0.1:9090
-proxies:
-  - name: 默认节点
-    type: http
-    server: example.com
-    port: 443
-    username: username
-    password: password
-    tls: true
-proxy-groups:
-  - name: 🚀 节点选择
-    type: select
-    proxies:
-      - 默认节点
-      - DIRECT
-rules:
-  - MATCH,🚀 节点选择
-`
+// name 返回清理后且唯一的代理名称
+func (u lineNames) name(raw string) string {
+	name := lineProxyName(raw)
+	unique := name
+	for i := 2; u[unique]; i++ {
+		unique = fmt.Sprintf("%s %d", name, i)
+	}
+	u[unique] = true
+	return unique
 }
 
 func (c *Converter) DetectClientType(userAgent string) ConverterType {
 	userAgent = strings.ToLower(userAgent)
 
 	switch {
+	case strings.Contains(userAgent, "surge"):
+		return TypeSurge
+	case strings.Contains(userAgent, "loon"):
+		return TypeLoon
+	case strings.Contains(userAgent, "quantumult"):
+		return TypeQuanX
+	case strings.Contains(userAgent, "stash"):
+		return TypeClash
 	case strings.Contains(userAgent, "clash") && !strings.Contains(userAgent, "nekobox"):
 		return TypeClash
 	case strings.Contains(userAgent, "sing-box") || strings.Contains(userAgent, "singbox"):
@@ -347,11 +268,19 @@ func (c *Converter) DetectClientType(userAgent string) ConverterType {
 	}
 }
 
-func (c *Converter) GetResponseHeaders(filename string) map[string]string {
-	return map[string]string{
-		"Content-Disposition": fmt.Sprintf(`attachment; filename*=utf-8''%s; filename=%s`,
-			url.QueryEscape(filename), filename),
+func (c *Converter) GetResponseHeaders(filename string, targetType ConverterType, userInfo *UserInfo) map[string]string {
+	headers := map[string]string{
 		"content-type":            "text/plain; charset=utf-8",
 		"Profile-Update-Interval": fmt.Sprintf("%d", c.updateTime),
 	}
+	// Clash、sing-box 等客户端据此显示剩余流量和到期时间
+	if userInfo != nil {
+		headers["Subscription-Userinfo"] = userInfo.String()
+	}
+	// 明文格式直接在浏览器中显示，不作为附件下载
+	if targetType != TypeRaw {
+		headers["Content-Disposition"] = fmt.Sprintf(`attachment; filename*=utf-8''%s; filename=%s`,
+			url.QueryEscape(filename), filename)
+	}
+	return headers
 }
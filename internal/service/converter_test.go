@@ -0,0 +1,18 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLineNamesUnique(t *testing.T) {
+	used := newLineNames()
+	var got []string
+	for _, raw := range []string{"A,B", "A B", "A=B", "A  B 2", "DIRECT"} {
+		got = append(got, used.name(raw))
+	}
+	want := []string{"A B", "A B 2", "A B 3", "A B 2 2", "DIRECT 2"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("得到 %q，期望 %q", got, want)
+	}
+}
@@ -0,0 +1,158 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"sublinks/config"
+)
+
+// fetchResult 一次订阅请求的结果
+type fetchResult struct {
+	content      string
+	etag         string
+	lastModified string
+	// userInfo 上游返回的 Subscription-Userinfo，没有时为nil
+	userInfo *UserInfo
+	// notModified 上游返回304，内容与缓存一致
+	notModified bool
+}
+
+// errPermanent 标记无需重试的错误，如 404 或内容超出大小限制
+type errPermanent struct {
+	err error
+}
+
+func (e *errPermanent) Error() string { return e.err.Error() }
+func (e *errPermanent) Unwrap() error { return e.err }
+
+// Fetcher 请求上游订阅，负责超时、失败重试和并发数限制
+type Fetcher struct {
+	client    *http.Client
+	timeout   time.Duration
+	retries   int
+	backoff   time.Duration
+	maxBody   int64
+	userAgent string
+	// slots 限制同时进行的上游请求数量
+	slots chan struct{}
+}
+
+// NewFetcher 根据配置创建上游请求器，不大于0的设置使用默认值
+func NewFetcher(cfg config.FetchConfig) *Fetcher {
+	f := &Fetcher{
+		client:    &http.Client{},
+		timeout:   30 * time.Second,
+		retries:   cfg.Retries,
+		backoff:   time.Second,
+		maxBody:   10 << 20,
+		userAgent: cfg.UserAgent,
+	}
+	if cfg.Timeout > 0 {
+		f.timeout = time.Duration(cfg.Timeout) * time.Second
+	}
+	if cfg.RetryBackoff > 0 {
+		f.backoff = time.Duration(cfg.RetryBackoff) * time.Second
+	}
+	if cfg.MaxBodySize > 0 {
+		f.maxBody = int64(cfg.MaxBodySize) << 20
+	}
+	if f.retries < 0 {
+		f.retries = 0
+	}
+
+	concurrency := cfg.Concurrency
+	if concurrency <= 0 {
+		concurrency = 8
+	}
+	f.slots = make(chan struct{}, concurrency)
+	return f
+}
+
+// Fetch 获取订阅内容，etag/lastModified 非空时发送条件请求。
+// 网络错误、429 和 5xx 按退避间隔重试，其余错误直接返回
+func (f *Fetcher) Fetch(source config.Source, etag, lastModified string) (*fetchResult, error) {
+	timeout := f.timeout
+	if source.Timeout > 0 {
+		timeout = time.Duration(source.Timeout) * time.Second
+	}
+	userAgent := f.userAgent
+	if source.UserAgent != "" {
+		userAgent = source.UserAgent
+	}
+
+	backoff := f.backoff
+	for attempt := 0; ; attempt++ {
+		// 等待重试期间不占用并发名额
+		f.slots <- struct{}{}
+		result, err := f.fetchOnce(source.URL, userAgent, source.Headers, etag, lastModified, timeout)
+		<-f.slots
+
+		var permanent *errPermanent
+		if err == nil || errors.As(err, &permanent) || attempt >= f.retries {
+			return result, err
+		}
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+func (f *Fetcher) fetchOnce(url, userAgent string, headers map[string]string, etag, lastModified string, timeout time.Duration) (*fetchResult, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, &errPermanent{fmt.Errorf("创建订阅请求失败: %w", err)}
+	}
+	if userAgent != "" {
+		req.Header.Set("User-Agent", userAgent)
+	}
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+	if lastModified != "" {
+		req.Header.Set("If-Modified-Since", lastModified)
+	}
+
+	// 发送HTTP请求获取订阅内容
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("获取订阅内容失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	userInfo := ParseUserInfo(resp.Header.Get("Subscription-Userinfo"))
+	if resp.StatusCode == http.StatusNotModified {
+		return &fetchResult{userInfo: userInfo, notModified: true}, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		err := fmt.Errorf("订阅源返回错误状态码: %d", resp.StatusCode)
+		if resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode < 500 {
+			return nil, &errPermanent{err}
+		}
+		return nil, err
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, f.maxBody+1))
+	if err != nil {
+		return nil, fmt.Errorf("读取订阅内容失败: %w", err)
+	}
+	if int64(len(body)) > f.maxBody {
+		return nil, &errPermanent{fmt.Errorf("订阅内容超过大小限制 %d MB", f.maxBody>>20)}
+	}
+
+	return &fetchResult{
+		content:      string(body),
+		etag:         resp.Header.Get("ETag"),
+		lastModified: resp.Header.Get("Last-Modified"),
+		userInfo:     userInfo,
+	}, nil
+}
@@ -0,0 +1,260 @@
+package service
+
+import (
+	"strings"
+
+	"sublinks/config"
+	"sublinks/internal/acl"
+	"sublinks/internal/region"
+)
+
+// ProxyGroup 输出配置中的代理组
+type ProxyGroup struct {
+	Name string
+	// Type 使用Clash的代理组类型：select/url-test/fallback/load-balance
+	Type      string
+	Proxies   []string
+	URL       string
+	Interval  int
+	Tolerance int
+}
+
+// Rule 分流规则，Type 使用Clash的规则类型，MATCH 表示兜底规则
+type Rule struct {
+	Type      string
+	Value     string
+	Target    string
+	NoResolve bool
+}
+
+// Layout 输出配置中的代理组与分流规则，由各格式的渲染器转换为对应语法
+type Layout struct {
+	Groups []ProxyGroup
+	Rules  []Rule
+}
+
+// loadTemplate 加载 sub_config 指向的 ini 配置，未配置时返回nil
+func (c *Converter) loadTemplate() (*acl.Template, error) {
+	if c.configFile == "" {
+		return nil, nil
+	}
+	return c.templates.Load(c.configFile)
+}
+
+// builtinPolicies 客户端内置的策略名称
+var builtinPolicies = []string{"DIRECT", "REJECT", "REJECT-DROP", "PASS", "COMPATIBLE", "GLOBAL"}
+
+// ReservedNames 返回节点不能使用的名称：内置策略、默认代理组、ini 配置中的代理组，
+// 以及启用地区分组时所有可能的地区代理组名称
+func (c *Converter) ReservedNames() []string {
+	names := append([]string{groupSelect, groupAuto}, builtinPolicies...)
+	if t, err := c.loadTemplate(); err == nil && t != nil {
+		for _, g := range t.ProxyGroups {
+			names = append(names, g.Name)
+		}
+	}
+	if c.regionGroups.Enabled {
+		for _, r := range region.All() {
+			names = append(names, r.Flag+" "+r.Name, r.Flag+" "+r.EnglishName)
+		}
+	}
+	return names
+}
+
+// layout 生成代理组与规则，没有可用的 ini 配置时使用默认布局
+func (c *Converter) layout(t *acl.Template, names []string) Layout {
+	var layout Layout
+	if t == nil || len(t.ProxyGroups) == 0 {
+		layout = defaultLayout(names)
+	} else {
+		layout = templateLayout(t, names)
+	}
+
+	if c.regionGroups.Enabled {
+		addRegionGroups(&layout, names, c.regionGroups)
+	}
+	return layout
+}
+
+// defaultLayout 默认布局：手动选择、自动测速两个代理组和一条兜底规则
+func defaultLayout(names []string) Layout {
+	return Layout{
+		Groups: []ProxyGroup{
+			{
+				Name:    groupSelect,
+				Type:    "select",
+				Proxies: append([]string{groupAuto, "DIRECT"}, names...),
+			},
+			{
+				Name:      groupAuto,
+				Type:      "url-test",
+				Proxies:   names,
+				URL:       testURL,
+				Interval:  300,
+				Tolerance: 50,
+			},
+		},
+		Rules: []Rule{{Type: "MATCH", Target: groupSelect}},
+	}
+}
+
+// templateLayout 根据 ini 中的 custom_proxy_group 和 ruleset 生成布局
+func templateLayout(t *acl.Template, names []string) Layout {
+	var layout Layout
+	for _, g := range t.ProxyGroups {
+		group := ProxyGroup{
+			Name:      g.Name,
+			Type:      g.Type,
+			Proxies:   g.Resolve(names),
+			URL:       g.URL,
+			Interval:  g.Interval,
+			Tolerance: g.Tolerance,
+		}
+		if group.Type != "select" {
+			if group.URL == "" {
+				group.URL = testURL
+			}
+			if group.Interval <= 0 {
+				group.Interval = 300
+			}
+		}
+		layout.Groups = append(layout.Groups, group)
+	}
+
+	if t.EnableRuleGenerator {
+		for _, rs := range t.Rulesets {
+			for _, r := range rs.Rules {
+				layout.Rules = append(layout.Rules, Rule{
+					Type:      r.Type,
+					Value:     r.Value,
+					Target:    rs.Group,
+					NoResolve: hasOption(r.Options, "no-resolve"),
+				})
+			}
+		}
+	}
+
+	// 确保存在兜底规则
+	if n := len(layout.Rules); n == 0 || layout.Rules[n-1].Type != "MATCH" {
+		layout.Rules = append(layout.Rules, Rule{Type: "MATCH", Target: layout.Groups[0].Name})
+	}
+	return layout
+}
+
+func hasOption(options []string, option string) bool {
+	for _, o := range options {
+		if strings.EqualFold(o, option) {
+			return true
+		}
+	}
+	return false
+}
+
+// regionGroups 按节点名称识别地区，为每个地区生成一个代理组，顺序与地区列表一致
+func regionGroups(names []string, cfg config.RegionGroupConfig) []ProxyGroup {
+	members := make(map[string][]string)
+	for _, name := range names {
+		if r, ok := region.Detect(name); ok {
+			members[r.Code] = append(members[r.Code], name)
+		}
+	}
+
+	var codes []string
+	if len(cfg.Regions) > 0 {
+		for _, code := range cfg.Regions {
+			if r, ok := region.Lookup(code); ok {
+				codes = append(codes, r.Code)
+			}
+		}
+	} else {
+		for _, r := range region.All() {
+			codes = append(codes, r.Code)
+		}
+	}
+
+	var groups []ProxyGroup
+	for _, code := range codes {
+		proxies := members[code]
+		if len(proxies) == 0 || len(proxies) < cfg.MinNodes {
+			continue
+		}
+		r, _ := region.Lookup(code)
+		name := r.Name
+		if cfg.English {
+			name = r.EnglishName
+		}
+
+		group := ProxyGroup{
+			Name:     r.Flag + " " + name,
+			Type:     cfg.Type,
+			Proxies:  proxies,
+			URL:      firstNonEmpty(cfg.URL, testURL),
+			Interval: cfg.Interval,
+		}
+		if group.Type != "fallback" {
+			group.Type = "url-test"
+			group.Tolerance = cfg.Tolerance
+		}
+		if group.Interval <= 0 {
+			group.Interval = 300
+		}
+		groups = append(groups, group)
+	}
+	return groups
+}
+
+// addRegionGroups 添加地区代理组和全局自动选择组，并将它们加入第一个代理组供手动选择
+func addRegionGroups(layout *Layout, names []string, cfg config.RegionGroupConfig) {
+	groups := regionGroups(names, cfg)
+	if len(groups) == 0 {
+		return
+	}
+
+	existing := make(map[string]bool)
+	for _, g := range layout.Groups {
+		existing[g.Name] = true
+	}
+	if !existing[groupAuto] {
+		layout.Groups = append(layout.Groups, ProxyGroup{
+			Name:      groupAuto,
+			Type:      "url-test",
+			Proxies:   names,
+			URL:       firstNonEmpty(cfg.URL, testURL),
+			Interval:  300,
+			Tolerance: 50,
+		})
+	}
+
+	var added []string
+	for _, g := range groups {
+		if existing[g.Name] {
+			continue
+		}
+		layout.Groups = append(layout.Groups, g)
+		added = append(added, g.Name)
+	}
+
+	// 地区组排在第一个代理组中自动选择组之后、其他成员之前
+	first := &layout.Groups[0]
+	if first.Type != "select" {
+		return
+	}
+	var proxies []string
+	rest := first.Proxies
+	if len(rest) > 0 && rest[0] == groupAuto {
+		proxies, rest = append(proxies, groupAuto), rest[1:]
+	} else if first.Name != groupAuto && !contains(rest, groupAuto) {
+		proxies = append(proxies, groupAuto)
+	}
+	proxies = append(proxies, added...)
+	first.Proxies = append(proxies, rest...)
+}
+
+func contains(list []string, value string) bool {
+	for _, v := range list {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
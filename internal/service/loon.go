@@ -0,0 +1,191 @@
+package service
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"sublinks/internal/acl"
+	"sublinks/internal/node"
+)
+
+// buildLoonConfig 根据节点列表构建Loon配置
+func (c *Converter) buildLoonConfig(nodes []*node.Node, t *acl.Template) (string, error) {
+	var proxies, names []string
+	used := newLineNames()
+	skipped := 0
+	for i, n := range nodes {
+		params, ok := loonProxy(n)
+		if !ok {
+			skipped++
+			continue
+		}
+		name := used.name(proxyName(n, i))
+		proxies = append(proxies, name+" = "+strings.Join(params, ","))
+		names = append(names, name)
+	}
+
+	if skipped > 0 {
+		log.Printf("Loon配置跳过 %d 个不支持的节点", skipped)
+	}
+	if len(names) == 0 {
+		return "", ErrNoNodes
+	}
+	log.Printf("生成Loon配置，共 %d 个节点", len(names))
+
+	var b strings.Builder
+	b.WriteString("[General]\n")
+	b.WriteString("skip-proxy = 127.0.0.1,192.168.0.0/16,10.0.0.0/8,172.16.0.0/12,100.64.0.0/10,localhost,*.local\n")
+	b.WriteString("dns-server = system,223.5.5.5,119.29.29.29\n")
+	fmt.Fprintf(&b, "proxy-test-url = %s\n\n", testURL)
+
+	b.WriteString("[Proxy]\n")
+	for _, line := range proxies {
+		b.WriteString(line + "\n")
+	}
+
+	layout := c.layout(t, names)
+	b.WriteString("\n[Proxy Group]\n")
+	for _, g := range layout.Groups {
+		b.WriteString(lineGroup(g, ",") + "\n")
+	}
+
+	b.WriteString("\n[Rule]\n")
+	for _, r := range layout.Rules {
+		if line, ok := loonRule(r); ok {
+			b.WriteString(line + "\n")
+		}
+	}
+	return b.String(), nil
+}
+
+// loonRuleTypes Loon 支持的规则类型
+var loonRuleTypes = map[string]bool{
+	"DOMAIN":         true,
+	"DOMAIN-SUFFIX":  true,
+	"DOMAIN-KEYWORD": true,
+	"IP-CIDR":        true,
+	"IP-CIDR6":       true,
+	"GEOIP":          true,
+	"USER-AGENT":     true,
+	"URL-REGEX":      true,
+}
+
+// loonRule 生成Loon规则行，不支持的规则类型返回false
+func loonRule(r Rule) (string, bool) {
+	target := lineProxyName(r.Target)
+	if r.Type == "MATCH" {
+		return "FINAL," + target, true
+	}
+	if !loonRuleTypes[r.Type] {
+		return "", false
+	}
+	line := r.Type + "," + r.Value + "," + target
+	if r.NoResolve {
+		line += ",no-resolve"
+	}
+	return line, true
+}
+
+// loonProxy 生成Loon代理行的参数部分，不支持的节点返回false
+func loonProxy(n *node.Node) ([]string, bool) {
+	p := lineParams{}
+	server, port := n.Server, strconv.Itoa(n.Port)
+
+	switch n.Protocol {
+	case node.ProtocolShadowsocks:
+		password, ok := quoteValue(n.Password)
+		if !ok {
+			return nil, false
+		}
+		p.add("Shadowsocks", server, port, n.Cipher, password)
+		switch n.Plugin {
+		case "":
+		case "obfs-local", "simple-obfs", "obfs":
+			opts := n.PluginOptions()
+			p.set("obfs-name", opts["obfs"])
+			p.set("obfs-host", opts["obfs-host"])
+		default:
+			return nil, false
+		}
+		p.setBool("udp", true)
+	case node.ProtocolSSR:
+		password, ok := quoteValue(n.Password)
+		if !ok {
+			return nil, false
+		}
+		p.add("ShadowsocksR", server, port, n.Cipher, password)
+		p.set("protocol", n.SSRProtocol)
+		p.set("protocol-param", n.SSRProtocolParam)
+		p.set("obfs", n.Obfs)
+		p.set("obfs-param", n.ObfsParam)
+	case node.ProtocolVMess:
+		p.add("vmess", server, port, firstNonEmpty(n.Cipher, "auto"), `"`+n.UUID+`"`)
+		p.set("alterId", strconv.Itoa(n.AlterID))
+		if !setLoonTransport(&p, n) {
+			return nil, false
+		}
+	case node.ProtocolVLESS:
+		p.add("VLESS", server, port, `"`+n.UUID+`"`)
+		p.set("flow", n.Flow)
+		if !setLoonTransport(&p, n) {
+			return nil, false
+		}
+	case node.ProtocolTrojan:
+		password, ok := quoteValue(n.Password)
+		if !ok {
+			return nil, false
+		}
+		p.add("trojan", server, port, password)
+		if !setLoonTransport(&p, n) {
+			return nil, false
+		}
+	case node.ProtocolHysteria2:
+		password, ok := quoteValue(n.Password)
+		if !ok || n.Obfs != "" {
+			return nil, false
+		}
+		p.add("Hysteria2", server, port, password)
+		p.set("tls-name", n.TLS.SNI)
+		p.setBool("skip-cert-verify", n.TLS.Insecure)
+		if n.DownMbps > 0 {
+			p.set("download-bandwidth", strconv.Itoa(n.DownMbps))
+		}
+		p.setBool("udp", true)
+	default:
+		return nil, false
+	}
+	return p, true
+}
+
+// setLoonTransport 设置传输层与TLS参数，Loon 支持 tcp/ws/http 传输
+func setLoonTransport(p *lineParams, n *node.Node) bool {
+	switch n.Transport.Type {
+	case "", "tcp":
+		if n.Transport.HeaderType == "http" {
+			p.set("transport", "http")
+			p.set("path", n.Transport.Path)
+			p.set("host", n.Transport.Host)
+		} else {
+			p.set("transport", "tcp")
+		}
+	case "ws":
+		p.set("transport", "ws")
+		p.set("path", n.Transport.Path)
+		p.set("host", n.Transport.Host)
+	default:
+		return false
+	}
+
+	if n.TLS.Enabled {
+		p.setBool("over-tls", true)
+		p.set("tls-name", n.TLS.SNI)
+		p.setBool("skip-cert-verify", n.TLS.Insecure)
+		if reality := n.TLS.Reality; reality != nil {
+			p.set("public-key", `"`+reality.PublicKey+`"`)
+			p.set("short-id", reality.ShortID)
+		}
+	}
+	return true
+}
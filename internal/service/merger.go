@@ -1,133 +1,330 @@
 package service
 
 import (
-	"bufio"
-	"encoding/base64"
 	"fmt"
-	"io"
-	"net/http"
-	"strings"
+	"log"
+	"math"
 	"sync"
+	"time"
 
 	"sublinks/config"
+	"sublinks/internal/filter"
+	"sublinks/internal/node"
+	"sublinks/internal/rename"
 )
 
+// maxReportLineLength 报告中保留的原始行最大长度
+const maxReportLineLength = 120
+
+// 重复节点的处理方式
+const (
+	DedupKeepFirst = "keep_first"
+	DedupPriority  = "priority"
+	DedupOff       = "off"
+)
+
+// InvalidNode 无法解析的节点
+type InvalidNode struct {
+	Source string `json:"source"`
+	Line   string `json:"line"`
+	Reason string `json:"reason"`
+}
+
+// SourceStatus 订阅源在最近一次合并中的获取结果
+type SourceStatus struct {
+	Name  string `json:"name"`
+	URL   string `json:"url"`
+	Nodes int    `json:"nodes"`
+	// Error 请求上游失败的原因
+	Error string `json:"error,omitempty"`
+	// Stale 请求上游失败，使用的是上一次成功获取的内容
+	Stale bool `json:"stale,omitempty"`
+	// UserInfo 上游返回的流量和到期信息
+	UserInfo *UserInfo `json:"userinfo,omitempty"`
+}
+
+// MergeReport 最近一次合并的统计信息
+type MergeReport struct {
+	Time    time.Time `json:"time"`
+	Total   int       `json:"total"`
+	Skipped int       `json:"skipped"`
+	// Filtered 被过滤规则排除的节点数
+	Filtered int `json:"filtered"`
+	// Duplicates 去重移除的节点数
+	Duplicates int            `json:"duplicates"`
+	Invalid    []InvalidNode  `json:"invalid"`
+	Sources    []SourceStatus `json:"sources"`
+	// User 发起本次合并的用户，为空时为 my_token
+	User string `json:"user,omitempty"`
+	// UserInfo 返回给客户端的流量信息，按 userinfo_source 汇总或取自指定订阅源
+	UserInfo *UserInfo `json:"userinfo,omitempty"`
+}
+
+// MergeOptions 单次合并的参数
+type MergeOptions struct {
+	// Sources 参与合并的订阅源
+	Sources []config.Source
+	// SkipMain 不包含 main_data 中的节点
+	SkipMain bool
+	// User 发起合并的用户名，记录在报告中
+	User string
+	// ReservedNames 内置策略和代理组名称，节点不能使用
+	ReservedNames []string
+	// Filters 用户和请求中的过滤规则，与全局规则一样在去重和重命名之前按原名称匹配
+	Filters []*filter.Filter
+}
+
+// AllSourcesOptions 合并所有订阅源和 main_data
+func AllSourcesOptions() MergeOptions {
+	return MergeOptions{Sources: config.GetAllSources()}
+}
+
+// UserMergeOptions 只合并用户可使用的订阅源，用户未限制订阅源时合并全部
+func UserMergeOptions(u config.User) MergeOptions {
+	opts := AllSourcesOptions()
+	opts.User = u.Name
+	if len(u.Sources) == 0 {
+		return opts
+	}
+
+	allowed := make(map[string]bool, len(u.Sources))
+	for _, s := range u.Sources {
+		allowed[s] = true
+	}
+	var selected []config.Source
+	for _, s := range opts.Sources {
+		if allowed[s.Name] || allowed[s.URL] {
+			selected = append(selected, s)
+		}
+	}
+	opts.Sources = selected
+	opts.SkipMain = !allowed[node.SourceMain]
+	return opts
+}
+
 // NodeMerger 处理节点合并的服务
 type NodeMerger struct {
 	mainData string
+	// strict 为true时丢弃无法解析的节点，否则在分享链接输出中原样透传
+	strict bool
+	// filter 对所有节点生效的过滤规则
+	filter *filter.Filter
+	// cache 上游订阅内容缓存
+	cache *SourceCache
+	// renamer 节点重命名规则，合并后的节点名称保证唯一
+	renamer *rename.Renamer
+	// dedupPolicy 重复节点的处理方式
+	dedupPolicy string
+	// userInfoSource 返回给客户端的流量信息来源
+	userInfoSource string
+
+	reportMutex sync.RWMutex
+	// reports 按用户名记录最近一次合并的统计信息，my_token 和不限用户的分享链接记录在空用户名下
+	reports map[string]MergeReport
 }
 
 // NewNodeMerger 创建新的节点合并服务
-func NewNodeMerger(mainData string) *NodeMerger {
+func NewNodeMerger(cfg *config.Config) *NodeMerger {
+	f, err := filter.New(cfg.Filter)
+	if err != nil {
+		log.Printf("全局过滤规则无效，已忽略: %v", err)
+	}
+	renamer, err := rename.New(cfg.Rename)
+	if err != nil {
+		log.Printf("重命名规则无效，已忽略: %v", err)
+		renamer, _ = rename.New(config.RenameConfig{})
+	}
 	return &NodeMerger{
-		mainData: mainData,
+		mainData:       cfg.MainData,
+		strict:         cfg.StrictMode,
+		filter:         f,
+		cache:          NewSourceCache(time.Duration(cfg.SUBUpdateTime)*time.Hour, NewFetcher(cfg.Fetch)),
+		renamer:        renamer,
+		dedupPolicy:    cfg.DedupPolicy,
+		userInfoSource: cfg.UserInfoSource,
+		reports:        make(map[string]MergeReport),
 	}
 }
 
-// MergeNodes 合并所有节点数据
-func (m *NodeMerger) MergeNodes() (string, error) {
-	// 处理主数据
-	nodes := m.parseMainData()
+// LastReport 返回用户最近一次合并的统计信息，user 为空时返回使用全部订阅源的合并；
+// 该用户还没有合并过时返回 false
+func (m *NodeMerger) LastReport(user string) (MergeReport, bool) {
+	m.reportMutex.RLock()
+	defer m.reportMutex.RUnlock()
+	report, ok := m.reports[user]
+	return report, ok
+}
 
-	// 获取所有订阅URL
-	urls := config.GetAllSubscribeURLs()
+// MergeNodes 合并 main_data 和指定订阅源的节点，返回节点列表和本次合并的统计信息
+func (m *NodeMerger) MergeNodes(opts MergeOptions) ([]*node.Node, MergeReport, error) {
+	report := MergeReport{Time: time.Now(), Invalid: []InvalidNode{}, User: opts.User}
 
-	// 并发获取订阅内容
+	// 处理主数据
+	var nodes []*node.Node
+	if !opts.SkipMain {
+		nodes = m.parseContent(m.mainData, node.SourceMain, &report)
+	}
+	sources := opts.Sources
+
+	// 并发获取订阅内容，优先使用缓存，同时请求上游的数量由 Fetcher 限制
 	var wg sync.WaitGroup
-	nodesChan := make(chan string, len(urls))
+	contents := make([]string, len(sources))
+	report.Sources = make([]SourceStatus, len(sources))
 
-	for _, url := range urls {
+	for i, source := range sources {
+		report.Sources[i] = SourceStatus{Name: source.Name, URL: source.URL}
 		wg.Add(1)
-		go func(url string) {
+		go func(i int, source config.Source) {
 			defer wg.Done()
-			if content, err := fetchSubscription(url); err == nil {
-				nodesChan <- content
+			cached, err := m.cache.Get(source)
+			report.Sources[i].UserInfo = cached.userInfo
+			if err != nil {
+				report.Sources[i].Error = err.Error()
+				report.Sources[i].Stale = cached.stale
+				if cached.stale {
+					log.Printf("获取订阅 %s 失败，使用缓存内容: %v", source.Name, err)
+				} else {
+					log.Printf("获取订阅 %s 失败: %v", source.Name, err)
+				}
 			}
-		}(url)
+			contents[i] = cached.content
+		}(i, source)
 	}
 
 	// 等待所有goroutine完成
-	go func() {
-		wg.Wait()
-		close(nodesChan)
-	}()
+	wg.Wait()
+	m.retainCache()
 
-	// 收集所有节点
-	for content := range nodesChan {
-		nodes = append(nodes, m.parseContent(content)...)
+	// 按订阅顺序解析所有节点，并应用订阅源自己的过滤规则
+	for i, content := range contents {
+		parsed := m.parseContent(content, sources[i].Name, &report)
+		report.Sources[i].Nodes = len(parsed)
+
+		sourceFilter, err := filter.New(sources[i].Filter)
+		if err != nil {
+			log.Printf("订阅源 %s 的过滤规则无效，已忽略: %v", sources[i].Name, err)
+		}
+		kept := sourceFilter.Apply(parsed)
+		report.Filtered += len(parsed) - len(kept)
+		nodes = append(nodes, kept...)
+	}
+
+	// 全局、用户和请求的过滤规则
+	for _, f := range append([]*filter.Filter{m.filter}, opts.Filters...) {
+		kept := f.Apply(nodes)
+		report.Filtered += len(nodes) - len(kept)
+		nodes = kept
 	}
 
 	// 去重
-	uniqueNodes := m.removeDuplicates(nodes)
+	deduped := m.removeDuplicates(nodes, sources)
+	report.Duplicates = len(nodes) - len(deduped)
+	nodes = deduped
 
-	// 合并为最终结果
-	result := strings.Join(uniqueNodes, "\n")
-	return base64.StdEncoding.EncodeToString([]byte(result)), nil
-}
+	report.UserInfo = m.userInfo(report.Sources)
 
-// parseMainData 解析主数据
-func (m *NodeMerger) parseMainData() []string {
-	var nodes []string
-	scanner := bufio.NewScanner(strings.NewReader(m.mainData))
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
-		if line != "" {
-			nodes = append(nodes, line)
+	// 重命名，缺少名称的节点先按序号命名，再与其他节点一起保证名称唯一
+	for i, n := range nodes {
+		if n.Protocol != node.ProtocolUnknown && n.Name == "" {
+			n.Name = fmt.Sprintf("Node-%d", i+1)
 		}
 	}
-	return nodes
+	m.renamer.Apply(nodes, opts.ReservedNames)
+	report.Total = len(nodes)
+	report.Skipped = len(report.Invalid)
+
+	m.reportMutex.Lock()
+	m.reports[opts.User] = report
+	m.reportMutex.Unlock()
+
+	if report.Skipped > 0 {
+		log.Printf("合并完成: %d 个节点，%d 个无法解析", report.Total, report.Skipped)
+	}
+	return nodes, report, nil
 }
 
-// parseContent 解析订阅内容
-func (m *NodeMerger) parseContent(content string) []string {
-	// 尝试base64解码
-	decoded, err := base64.StdEncoding.DecodeString(content)
-	if err == nil {
-		content = string(decoded)
+// retainCache 删除已移除的订阅源的缓存
+func (m *NodeMerger) retainCache() {
+	sources := config.GetAllSources()
+	urls := make([]string, len(sources))
+	for i, s := range sources {
+		urls[i] = s.URL
 	}
+	m.cache.Retain(urls)
+}
 
-	var nodes []string
-	scanner := bufio.NewScanner(strings.NewReader(content))
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
-		if line != "" {
-			nodes = append(nodes, line)
+// userInfo 按 userinfo_source 汇总所有订阅源的流量信息，或取指定订阅源的信息
+func (m *NodeMerger) userInfo(sources []SourceStatus) *UserInfo {
+	switch m.userInfoSource {
+	case UserInfoNone:
+		return nil
+	case UserInfoSum:
+		infos := make([]*UserInfo, 0, len(sources))
+		for _, s := range sources {
+			infos = append(infos, s.UserInfo)
 		}
+		return SumUserInfo(infos)
 	}
-	return nodes
+
+	for _, s := range sources {
+		if s.Name == m.userInfoSource || s.URL == m.userInfoSource {
+			return s.UserInfo
+		}
+	}
+	return nil
 }
 
-// removeDuplicates 去除重复节点
-func (m *NodeMerger) removeDuplicates(nodes []string) []string {
-	seen := make(map[string]struct{})
-	var result []string
+// parseContent 自动识别格式并解析订阅内容，无法解析的节点记录到报告中
+func (m *NodeMerger) parseContent(content, source string, report *MergeReport) []*node.Node {
+	nodes, errs := node.ParseContent(content, source)
+	for _, err := range errs {
+		log.Printf("跳过无法解析的节点(%s): %v", source, err)
+
+		line := err.Line
+		if runes := []rune(line); len(runes) > maxReportLineLength {
+			line = string(runes[:maxReportLineLength]) + "..."
+		}
+		report.Invalid = append(report.Invalid, InvalidNode{
+			Source: source,
+			Line:   line,
+			Reason: err.Error(),
+		})
 
-	for _, node := range nodes {
-		if _, exists := seen[node]; !exists {
-			seen[node] = struct{}{}
-			result = append(result, node)
+		// 结构化格式中的节点没有可透传的分享链接
+		if !m.strict && !err.Structured {
+			nodes = append(nodes, node.NewUnknown(err.Line, source))
 		}
 	}
-	return result
+	return nodes
 }
 
-// fetchSubscription 获取订阅内容
-func fetchSubscription(url string) (string, error) {
-	// 发送HTTP请求获取订阅内容
-	resp, err := http.Get(url)
-	if err != nil {
-		return "", fmt.Errorf("获取订阅内容失败: %w", err)
+// removeDuplicates 按节点身份标识去除重复节点，结果保持首次出现的顺序。
+// 策略为 priority 时保留订阅源优先级最高的节点，主数据中的节点优先于所有订阅源
+func (m *NodeMerger) removeDuplicates(nodes []*node.Node, sources []config.Source) []*node.Node {
+	if m.dedupPolicy == DedupOff {
+		return nodes
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("订阅源返回错误状态码: %d", resp.StatusCode)
+	priorities := make(map[string]int, len(sources)+1)
+	for _, s := range sources {
+		priorities[s.Name] = s.Priority
 	}
+	priorities[node.SourceMain] = math.MaxInt
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return "", fmt.Errorf("读取订阅内容失败: %w", err)
-	}
+	index := make(map[string]int)
+	var result []*node.Node
 
-	return string(body), nil
+	for _, n := range nodes {
+		key := n.Key()
+		i, exists := index[key]
+		if !exists {
+			index[key] = len(result)
+			result = append(result, n)
+			continue
+		}
+		if m.dedupPolicy == DedupPriority && priorities[n.Source] > priorities[result[i].Source] {
+			result[i] = n
+		}
+	}
+	return result
 }
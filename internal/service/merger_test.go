@@ -0,0 +1,87 @@
+package service
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"sublinks/config"
+	"sublinks/internal/filter"
+)
+
+func mergeNames(t *testing.T, cfg config.Config, opts MergeOptions) []string {
+	t.Helper()
+	nodes, _, err := NewNodeMerger(&cfg).MergeNodes(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := make([]string, len(nodes))
+	for i, n := range nodes {
+		names[i] = n.Name
+	}
+	return names
+}
+
+func TestMergeNodesNamesUnique(t *testing.T) {
+	cfg := config.Config{
+		StrictMode: true,
+		MainData: strings.Join([]string{
+			"trojan://p1@a.example.com:443#Node-2",
+			"trojan://p2@b.example.com:443",
+			"trojan://p3@c.example.com:443#DIRECT",
+			"trojan://p4@d.example.com:443#" + groupSelect,
+		}, "\n"),
+	}
+	opts := MergeOptions{ReservedNames: []string{"DIRECT", groupSelect}}
+
+	want := []string{"Node-2", "Node-2 2", "DIRECT 2", groupSelect + " 2"}
+	if got := mergeNames(t, cfg, opts); !reflect.DeepEqual(got, want) {
+		t.Errorf("得到 %q，期望 %q", got, want)
+	}
+}
+
+func TestMergeNodesFiltersBeforeRename(t *testing.T) {
+	cfg := config.Config{
+		StrictMode: true,
+		MainData: strings.Join([]string{
+			"trojan://p1@a.example.com:443#HK 01",
+			"trojan://p2@b.example.com:443#JP 01",
+			"trojan://p3@c.example.com:443#HK 02",
+		}, "\n"),
+		Rename: config.RenameConfig{Template: "{region}-{index}"},
+	}
+	include, err := filter.New(config.FilterConfig{Include: "HK"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := MergeOptions{Filters: []*filter.Filter{nil, include}}
+
+	want := []string{"香港-1", "香港-2"}
+	if got := mergeNames(t, cfg, opts); !reflect.DeepEqual(got, want) {
+		t.Errorf("得到 %q，期望 %q", got, want)
+	}
+}
+
+func TestLastReportPerUser(t *testing.T) {
+	cfg := config.Config{StrictMode: true, MainData: "trojan://secret@example.com:443#A\nvmess://invalid"}
+	m := NewNodeMerger(&cfg)
+
+	if _, _, err := m.MergeNodes(MergeOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := m.MergeNodes(MergeOptions{User: "alice", SkipMain: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	all, ok := m.LastReport("")
+	if !ok || all.User != "" || all.Skipped != 1 || all.Total != 1 {
+		t.Errorf("全部订阅源的报告为 %+v, %v", all, ok)
+	}
+	alice, ok := m.LastReport("alice")
+	if !ok || alice.User != "alice" || alice.Skipped != 0 || alice.Total != 0 {
+		t.Errorf("alice 的报告为 %+v, %v", alice, ok)
+	}
+	if _, ok := m.LastReport("bob"); ok {
+		t.Error("bob 没有合并过，不应有报告")
+	}
+}
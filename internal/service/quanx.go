@@ -0,0 +1,233 @@
+package service
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"sublinks/internal/acl"
+	"sublinks/internal/node"
+)
+
+// buildQuanXConfig 根据节点列表构建Quantumult X配置
+func (c *Converter) buildQuanXConfig(nodes []*node.Node, t *acl.Template) (string, error) {
+	var servers, names []string
+	used := newLineNames()
+	skipped := 0
+	for i, n := range nodes {
+		params, ok := quanxServer(n)
+		if !ok {
+			skipped++
+			continue
+		}
+		name := used.name(proxyName(n, i))
+		params.set("tag", name)
+		servers = append(servers, strings.Join(params, ", "))
+		names = append(names, name)
+	}
+
+	if skipped > 0 {
+		log.Printf("Quantumult X配置跳过 %d 个不支持的节点", skipped)
+	}
+	if len(names) == 0 {
+		return "", ErrNoNodes
+	}
+	log.Printf("生成Quantumult X配置，共 %d 个节点", len(names))
+
+	var b strings.Builder
+	b.WriteString("[general]\n")
+	fmt.Fprintf(&b, "server_check_url = %s\n", testURL)
+	b.WriteString("dns_exclusion_list = *.cmpassport.com, *.jegotrip.com.cn, *.icitymobile.mobi, id6.me\n\n")
+
+	b.WriteString("[dns]\n")
+	b.WriteString("server = 223.5.5.5\n")
+	b.WriteString("server = 119.29.29.29\n\n")
+
+	layout := c.layout(t, names)
+	b.WriteString("[policy]\n")
+	for _, g := range layout.Groups {
+		b.WriteString(quanxPolicy(g) + "\n")
+	}
+
+	b.WriteString("\n[server_local]\n")
+	for _, line := range servers {
+		b.WriteString(line + "\n")
+	}
+
+	b.WriteString("\n[filter_local]\n")
+	for _, r := range layout.Rules {
+		if line, ok := quanxFilter(r); ok {
+			b.WriteString(line + "\n")
+		}
+	}
+	return b.String(), nil
+}
+
+// quanxPolicyTypes Clash代理组类型对应的Quantumult X策略类型
+var quanxPolicyTypes = map[string]string{
+	"select":       "static",
+	"url-test":     "url-latency-benchmark",
+	"fallback":     "available",
+	"load-balance": "round-robin",
+}
+
+// quanxPolicy 生成Quantumult X策略行
+func quanxPolicy(g ProxyGroup) string {
+	typ, ok := quanxPolicyTypes[g.Type]
+	if !ok {
+		typ = "static"
+	}
+	items := lineParams{lineProxyName(g.Name)}
+	for _, p := range g.Proxies {
+		items.add(quanxPolicyName(p))
+	}
+	if typ == "url-latency-benchmark" {
+		if g.Interval > 0 {
+			items.set("check-interval", strconv.Itoa(g.Interval))
+		}
+		if g.Tolerance > 0 {
+			items.set("tolerance", strconv.Itoa(g.Tolerance))
+		}
+	}
+	return typ + " = " + strings.Join(items, ", ")
+}
+
+// quanxFilterTypes Clash规则类型对应的Quantumult X分流类型
+var quanxFilterTypes = map[string]string{
+	"DOMAIN":         "host",
+	"DOMAIN-SUFFIX":  "host-suffix",
+	"DOMAIN-KEYWORD": "host-keyword",
+	"IP-CIDR":        "ip-cidr",
+	"IP-CIDR6":       "ip6-cidr",
+	"GEOIP":          "geoip",
+	"USER-AGENT":     "user-agent",
+}
+
+// quanxFilter 生成Quantumult X分流行，不支持的规则类型返回false
+func quanxFilter(r Rule) (string, bool) {
+	target := quanxPolicyName(r.Target)
+	if r.Type == "MATCH" {
+		return "final, " + target, true
+	}
+	typ, ok := quanxFilterTypes[r.Type]
+	if !ok {
+		return "", false
+	}
+	return typ + ", " + r.Value + ", " + target, true
+}
+
+// quanxPolicyName Quantumult X 内置策略使用小写名称
+func quanxPolicyName(name string) string {
+	switch name {
+	case "DIRECT", "REJECT":
+		return strings.ToLower(name)
+	}
+	return lineProxyName(name)
+}
+
+// quanxServer 生成Quantumult X服务器行，不支持的节点返回false
+func quanxServer(n *node.Node) (lineParams, bool) {
+	// QuanX 参数值不支持引号，包含逗号的密码无法表示
+	if strings.ContainsAny(n.Password, ",\r\n") {
+		return nil, false
+	}
+
+	p := lineParams{}
+	address := n.Address()
+
+	switch n.Protocol {
+	case node.ProtocolShadowsocks:
+		p.add("shadowsocks=" + address)
+		p.set("method", n.Cipher)
+		p.set("password", n.Password)
+		switch n.Plugin {
+		case "":
+		case "obfs-local", "simple-obfs", "obfs":
+			opts := n.PluginOptions()
+			p.set("obfs", opts["obfs"])
+			p.set("obfs-host", opts["obfs-host"])
+		default:
+			return nil, false
+		}
+		p.setBool("udp-relay", true)
+	case node.ProtocolSSR:
+		p.add("shadowsocks=" + address)
+		p.set("method", n.Cipher)
+		p.set("password", n.Password)
+		p.set("ssr-protocol", n.SSRProtocol)
+		p.set("ssr-protocol-param", n.SSRProtocolParam)
+		p.set("obfs", n.Obfs)
+		p.set("obfs-host", n.ObfsParam)
+	case node.ProtocolVMess:
+		method := n.Cipher
+		if method == "" || method == "auto" {
+			method = "chacha20-poly1305"
+		}
+		p.add("vmess=" + address)
+		p.set("method", method)
+		p.set("password", n.UUID)
+		if !setQuanXTransport(&p, n) {
+			return nil, false
+		}
+		p.set("aead", fmt.Sprint(n.AlterID == 0))
+	case node.ProtocolVLESS:
+		if n.Flow != "" || n.TLS.Reality != nil {
+			return nil, false
+		}
+		p.add("vless=" + address)
+		p.set("method", "none")
+		p.set("password", n.UUID)
+		if !setQuanXTransport(&p, n) {
+			return nil, false
+		}
+	case node.ProtocolTrojan:
+		p.add("trojan=" + address)
+		p.set("password", n.Password)
+		if !setQuanXTransport(&p, n) {
+			return nil, false
+		}
+	default:
+		return nil, false
+	}
+	return p, true
+}
+
+// setQuanXTransport 设置 obfs 传输参数，QuanX 使用 ws/wss/over-tls 表示传输方式
+func setQuanXTransport(p *lineParams, n *node.Node) bool {
+	tls := n.TLS.Enabled
+	switch n.Transport.Type {
+	case "", "tcp":
+		switch {
+		case n.Transport.HeaderType == "http":
+			if tls {
+				return false
+			}
+			p.set("obfs", "http")
+			p.set("obfs-host", n.Transport.Host)
+			p.set("obfs-uri", n.Transport.Path)
+		case tls && n.Protocol == node.ProtocolTrojan:
+			// trojan 的 TLS 通过独立参数开启
+			p.setBool("over-tls", true)
+			p.set("tls-host", n.TLS.SNI)
+		case tls:
+			p.set("obfs", "over-tls")
+			p.set("obfs-host", n.TLS.SNI)
+		}
+	case "ws":
+		if tls {
+			p.set("obfs", "wss")
+		} else {
+			p.set("obfs", "ws")
+		}
+		p.set("obfs-host", firstNonEmpty(n.Transport.Host, n.TLS.SNI))
+		p.set("obfs-uri", n.Transport.Path)
+	default:
+		return false
+	}
+
+	if tls {
+		p.set("tls-verification", fmt.Sprint(!n.TLS.Insecure))
+	}
+	return true
+}
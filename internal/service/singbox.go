@@ -0,0 +1,559 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"sublinks/internal/acl"
+	"sublinks/internal/node"
+)
+
+// singboxOutbound sing-box 出站配置
+type singboxOutbound struct {
+	Type              string            `json:"type"`
+	Tag               string            `json:"tag"`
+	Server            string            `json:"server,omitempty"`
+	ServerPort        int               `json:"server_port,omitempty"`
+	UUID              string            `json:"uuid,omitempty"`
+	Password          string            `json:"password,omitempty"`
+	Method            string            `json:"method,omitempty"`
+	Plugin            string            `json:"plugin,omitempty"`
+	PluginOpts        string            `json:"plugin_opts,omitempty"`
+	Security          string            `json:"security,omitempty"`
+	AlterID           int               `json:"alter_id,omitempty"`
+	Flow              string            `json:"flow,omitempty"`
+	UpMbps            int               `json:"up_mbps,omitempty"`
+	DownMbps          int               `json:"down_mbps,omitempty"`
+	Obfs              *singboxObfs      `json:"obfs,omitempty"`
+	ServerPorts       []string          `json:"server_ports,omitempty"` // 端口跳跃范围，如 "20000:30000"
+	CongestionControl string            `json:"congestion_control,omitempty"`
+	UDPRelayMode      string            `json:"udp_relay_mode,omitempty"`
+	TLS               *singboxTLS       `json:"tls,omitempty"`
+	Transport         *singboxTransport `json:"transport,omitempty"`
+}
+
+// singboxObfs Hysteria2 混淆配置
+type singboxObfs struct {
+	Type     string `json:"type"`
+	Password string `json:"password"`
+}
+
+// singboxTLS sing-box 出站TLS配置
+type singboxTLS struct {
+	Enabled    bool            `json:"enabled"`
+	ServerName string          `json:"server_name,omitempty"`
+	Insecure   bool            `json:"insecure,omitempty"`
+	ALPN       []string        `json:"alpn,omitempty"`
+	UTLS       *singboxUTLS    `json:"utls,omitempty"`
+	Reality    *singboxReality `json:"reality,omitempty"`
+}
+
+type singboxUTLS struct {
+	Enabled     bool   `json:"enabled"`
+	Fingerprint string `json:"fingerprint"`
+}
+
+type singboxReality struct {
+	Enabled   bool   `json:"enabled"`
+	PublicKey string `json:"public_key"`
+	ShortID   string `json:"short_id,omitempty"`
+}
+
+// singboxTransport sing-box V2Ray传输层配置
+type singboxTransport struct {
+	Type                string            `json:"type"`
+	Host                interface{}       `json:"host,omitempty"`
+	Path                string            `json:"path,omitempty"`
+	Headers             map[string]string `json:"headers,omitempty"`
+	ServiceName         string            `json:"service_name,omitempty"`
+	MaxEarlyData        int               `json:"max_early_data,omitempty"`
+	EarlyDataHeaderName string            `json:"early_data_header_name,omitempty"`
+}
+
+// singboxNodeOutbound 将节点转换为sing-box出站，不支持的协议返回false
+func singboxNodeOutbound(n *node.Node, tag string) (*singboxOutbound, bool) {
+	out := &singboxOutbound{
+		Type:       string(n.Protocol),
+		Tag:        tag,
+		Server:     n.Server,
+		ServerPort: n.Port,
+	}
+
+	switch n.Protocol {
+	case node.ProtocolVMess:
+		out.UUID = n.UUID
+		out.Security = n.Cipher
+		out.AlterID = n.AlterID
+		out.TLS = singboxNodeTLS(n.TLS)
+		out.Transport = singboxNodeTransport(n.Transport)
+	case node.ProtocolVLESS:
+		out.UUID = n.UUID
+		out.Flow = n.Flow
+		out.TLS = singboxNodeTLS(n.TLS)
+		out.Transport = singboxNodeTransport(n.Transport)
+	case node.ProtocolTrojan:
+		out.Password = n.Password
+		out.TLS = singboxNodeTLS(n.TLS)
+		out.Transport = singboxNodeTransport(n.Transport)
+	case node.ProtocolShadowsocks:
+		out.Type = "shadowsocks"
+		out.Method = n.Cipher
+		out.Password = n.Password
+		switch n.Plugin {
+		case "":
+		case "obfs-local", "simple-obfs", "obfs":
+			out.Plugin = "obfs-local"
+			out.PluginOpts = n.PluginOpts
+		case "v2ray-plugin":
+			out.Plugin = "v2ray-plugin"
+			out.PluginOpts = n.PluginOpts
+		default:
+			return nil, false
+		}
+	case node.ProtocolHysteria2:
+		out.Password = n.Password
+		out.UpMbps = n.UpMbps
+		out.DownMbps = n.DownMbps
+		if n.Obfs != "" {
+			out.Obfs = &singboxObfs{Type: n.Obfs, Password: n.ObfsPassword}
+		}
+		for _, hop := range splitPorts(n.Ports) {
+			out.ServerPorts = append(out.ServerPorts, strings.Replace(hop, "-", ":", 1))
+		}
+		out.TLS = singboxNodeTLS(n.TLS)
+	case node.ProtocolTUIC:
+		out.UUID = n.UUID
+		out.Password = n.Password
+		out.CongestionControl = n.CongestionControl
+		out.UDPRelayMode = n.UDPRelayMode
+		out.TLS = singboxNodeTLS(n.TLS)
+	default:
+		return nil, false
+	}
+	return out, true
+}
+
+// singboxNodeTLS 转换TLS配置，REALITY 需要同时启用uTLS
+func singboxNodeTLS(t node.TLS) *singboxTLS {
+	if !t.Enabled {
+		return nil
+	}
+
+	tls := &singboxTLS{
+		Enabled:    true,
+		ServerName: t.SNI,
+		Insecure:   t.Insecure,
+		ALPN:       t.ALPN,
+	}
+	fingerprint := t.Fingerprint
+	if t.Reality != nil {
+		tls.Reality = &singboxReality{
+			Enabled:   true,
+			PublicKey: t.Reality.PublicKey,
+			ShortID:   t.Reality.ShortID,
+		}
+		if fingerprint == "" {
+			fingerprint = "chrome"
+		}
+	}
+	if fingerprint != "" {
+		tls.UTLS = &singboxUTLS{Enabled: true, Fingerprint: fingerprint}
+	}
+	return tls
+}
+
+// singboxNodeTransport 转换传输层配置，tcp 无需额外配置
+func singboxNodeTransport(t node.Transport) *singboxTransport {
+	switch t.Type {
+	case "ws":
+		tr := &singboxTransport{Type: "ws", Path: t.Path}
+		if t.Host != "" {
+			tr.Headers = map[string]string{"Host": t.Host}
+		}
+		// 路径中的 ?ed= 参数对应 sing-box 的 early data 配置
+		if idx := strings.Index(t.Path, "?"); idx >= 0 {
+			if q, err := url.ParseQuery(t.Path[idx+1:]); err == nil && q.Get("ed") != "" {
+				if ed, err := strconv.Atoi(q.Get("ed")); err == nil {
+					tr.Path = t.Path[:idx]
+					tr.MaxEarlyData = ed
+					tr.EarlyDataHeaderName = "Sec-WebSocket-Protocol"
+				}
+			}
+		}
+		return tr
+	case "httpupgrade":
+		tr := &singboxTransport{Type: "httpupgrade", Path: t.Path}
+		if t.Host != "" {
+			tr.Host = t.Host
+		}
+		return tr
+	case "grpc":
+		return &singboxTransport{Type: "grpc", ServiceName: t.ServiceName}
+	case "h2":
+		tr := &singboxTransport{Type: "http", Path: t.Path}
+		if t.Host != "" {
+			tr.Host = strings.Split(t.Host, ",")
+		}
+		return tr
+	}
+	return nil
+}
+
+// splitPorts 拆分逗号分隔的端口范围
+func splitPorts(ports string) []string {
+	if ports == "" {
+		return nil
+	}
+	return strings.Split(ports, ",")
+}
+
+// singboxConfig sing-box 配置文件
+type singboxConfig struct {
+	Log          map[string]interface{}   `json:"log"`
+	DNS          singboxDNS               `json:"dns"`
+	Inbounds     []map[string]interface{} `json:"inbounds"`
+	Outbounds    []interface{}            `json:"outbounds"`
+	Route        singboxRoute             `json:"route"`
+	Experimental map[string]interface{}   `json:"experimental"`
+}
+
+type singboxDNS struct {
+	Servers  []map[string]interface{} `json:"servers"`
+	Rules    []map[string]interface{} `json:"rules"`
+	Final    string                   `json:"final"`
+	Strategy string                   `json:"strategy,omitempty"`
+}
+
+type singboxRoute struct {
+	Rules               []map[string]interface{} `json:"rules"`
+	RuleSet             []map[string]interface{} `json:"rule_set,omitempty"`
+	Final               string                   `json:"final"`
+	AutoDetectInterface bool                     `json:"auto_detect_interface"`
+}
+
+// singboxGroup sing-box 选择器与自动测速出站
+type singboxGroup struct {
+	Type      string   `json:"type"`
+	Tag       string   `json:"tag"`
+	Outbounds []string `json:"outbounds"`
+	Default   string   `json:"default,omitempty"`
+	URL       string   `json:"url,omitempty"`
+	Interval  string   `json:"interval,omitempty"`
+	Tolerance int      `json:"tolerance,omitempty"`
+}
+
+// singboxSchema 不同 sing-box 版本的配置差异
+type singboxSchema struct {
+	// ruleActions 1.11 起使用路由动作替代 dns/block 出站和入站嗅探
+	ruleActions bool
+	// tunAddress 1.10 起 tun 入站使用 address 替代 inet4_address
+	tunAddress bool
+	// portHopping 1.11 起 Hysteria2 支持 server_ports
+	portHopping bool
+}
+
+// newSingboxSchema 根据版本号（如 1.8、1.10、1.11）确定配置格式
+func newSingboxSchema(version string) singboxSchema {
+	minor := 11
+	parts := strings.Split(strings.TrimPrefix(version, "v"), ".")
+	if len(parts) >= 2 && parts[0] == "1" {
+		if v, err := strconv.Atoi(parts[1]); err == nil {
+			minor = v
+		}
+	}
+	return singboxSchema{
+		ruleActions: minor >= 11,
+		tunAddress:  minor >= 10,
+		portHopping: minor >= 11,
+	}
+}
+
+// buildSingBoxConfig 根据节点列表构建sing-box配置，无法转换的节点会被跳过
+func (c *Converter) buildSingBoxConfig(nodes []*node.Node, t *acl.Template) (string, error) {
+	schema := newSingboxSchema(c.singboxVersion)
+
+	var outbounds []interface{}
+	var tags []string
+	skipped := 0
+	for i, n := range nodes {
+		tag := proxyName(n, i)
+
+		out, ok := singboxNodeOutbound(n, tag)
+		if !ok {
+			skipped++
+			continue
+		}
+		if !schema.portHopping {
+			out.ServerPorts = nil
+		}
+		outbounds = append(outbounds, out)
+		tags = append(tags, tag)
+	}
+
+	if skipped > 0 {
+		log.Printf("sing-box配置跳过 %d 个不支持的节点", skipped)
+	}
+	if len(tags) == 0 {
+		return "", ErrNoNodes
+	}
+	log.Printf("生成sing-box配置，共 %d 个节点", len(tags))
+
+	layout := c.layout(t, tags)
+	var groups []interface{}
+	for _, g := range layout.Groups {
+		groups = append(groups, singboxLayoutGroup(g))
+	}
+	mainGroup := layout.Groups[0].Name
+
+	config := singboxConfig{
+		Log: map[string]interface{}{"level": "info", "timestamp": true},
+		DNS: singboxDNS{
+			Servers: []map[string]interface{}{
+				{"tag": "dns_proxy", "address": "tls://8.8.8.8", "detour": mainGroup},
+				{"tag": "dns_direct", "address": "https://223.5.5.5/dns-query", "detour": "direct"},
+			},
+			// 代理服务器域名直接解析，避免循环依赖
+			Rules: []map[string]interface{}{
+				{"outbound": "any", "server": "dns_direct"},
+			},
+			Final:    "dns_proxy",
+			Strategy: "prefer_ipv4",
+		},
+		Outbounds: append(groups, outbounds...),
+		Route: singboxRoute{
+			Final:               mainGroup,
+			AutoDetectInterface: true,
+		},
+		Experimental: map[string]interface{}{
+			"cache_file": map[string]interface{}{"enabled": true},
+			"clash_api":  map[string]interface{}{"external_controller": "127.0.0.1:9090"},
+		},
+	}
+
+	mixed := map[string]interface{}{
+		"type":        "mixed",
+		"tag":         "mixed-in",
+		"listen":      "127.0.0.1",
+		"listen_port": 7890,
+	}
+	tun := map[string]interface{}{
+		"type":         "tun",
+		"tag":          "tun-in",
+		"auto_route":   true,
+		"strict_route": true,
+		"stack":        "mixed",
+	}
+	if schema.tunAddress {
+		tun["address"] = []string{"172.19.0.1/30"}
+	} else {
+		tun["inet4_address"] = "172.19.0.1/30"
+	}
+
+	config.Outbounds = append(config.Outbounds, map[string]interface{}{"type": "direct", "tag": "direct"})
+	if schema.ruleActions {
+		config.Route.Rules = []map[string]interface{}{
+			{"action": "sniff"},
+			{"protocol": "dns", "action": "hijack-dns"},
+			{"ip_is_private": true, "outbound": "direct"},
+		}
+		// 1.11 起拦截使用 reject 动作，仅在代理组或兜底规则引用 REJECT 时保留 block 出站
+		if layoutUsesBlock(layout) {
+			config.Outbounds = append(config.Outbounds, map[string]interface{}{"type": "block", "tag": "block"})
+		}
+	} else {
+		mixed["sniff"] = true
+		tun["sniff"] = true
+		config.Outbounds = append(config.Outbounds,
+			map[string]interface{}{"type": "block", "tag": "block"},
+			map[string]interface{}{"type": "dns", "tag": "dns-out"})
+		config.Route.Rules = []map[string]interface{}{
+			{"protocol": "dns", "outbound": "dns-out"},
+			{"ip_is_private": true, "outbound": "direct"},
+		}
+	}
+
+	rules, ruleSets, final := singboxLayoutRules(layout.Rules, schema)
+	config.Route.Rules = append(config.Route.Rules, rules...)
+	config.Route.RuleSet = ruleSets
+	if final != "" {
+		config.Route.Final = final
+	}
+	config.Inbounds = []map[string]interface{}{tun, mixed}
+
+	data, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("生成sing-box配置失败: %w", err)
+	}
+	return string(data), nil
+}
+
+// singboxOutboundTag 将内置策略转换为 sing-box 出站标签：DIRECT、COMPATIBLE 为 direct，REJECT、REJECT-DROP 为 block；
+// PASS 和 GLOBAL 在 sing-box 中没有对应的出站，返回 false
+func singboxOutboundTag(name string) (string, bool) {
+	switch name {
+	case "DIRECT", "COMPATIBLE":
+		return "direct", true
+	case "REJECT", "REJECT-DROP":
+		return "block", true
+	case "PASS", "GLOBAL":
+		return "", false
+	}
+	return name, true
+}
+
+// singboxLayoutGroup 生成代理组出站，select 以外的类型均使用 urltest
+func singboxLayoutGroup(g ProxyGroup) *singboxGroup {
+	members := make([]string, 0, len(g.Proxies))
+	for _, p := range g.Proxies {
+		if tag, ok := singboxOutboundTag(p); ok {
+			members = append(members, tag)
+		}
+	}
+	if len(members) == 0 {
+		members = []string{"direct"}
+	}
+	if g.Type == "select" {
+		return &singboxGroup{Type: "selector", Tag: g.Name, Outbounds: members}
+	}
+
+	group := &singboxGroup{
+		Type:      "urltest",
+		Tag:       g.Name,
+		Outbounds: members,
+		URL:       g.URL,
+		Tolerance: g.Tolerance,
+	}
+	if g.Interval > 0 {
+		group.Interval = fmt.Sprintf("%ds", g.Interval)
+	}
+	return group
+}
+
+// layoutUsesBlock 判断代理组成员或兜底规则是否需要 block 出站
+func layoutUsesBlock(layout Layout) bool {
+	isBlock := func(name string) bool {
+		tag, _ := singboxOutboundTag(name)
+		return tag == "block"
+	}
+	for _, g := range layout.Groups {
+		for _, p := range g.Proxies {
+			if isBlock(p) {
+				return true
+			}
+		}
+	}
+	for _, r := range layout.Rules {
+		if r.Type == "MATCH" && isBlock(r.Target) {
+			return true
+		}
+	}
+	return false
+}
+
+// singboxRuleKeys Clash规则类型对应的 sing-box 路由规则字段
+var singboxRuleKeys = map[string]string{
+	"DOMAIN":         "domain",
+	"DOMAIN-SUFFIX":  "domain_suffix",
+	"DOMAIN-KEYWORD": "domain_keyword",
+	"DOMAIN-REGEX":   "domain_regex",
+	"IP-CIDR":        "ip_cidr",
+	"IP-CIDR6":       "ip_cidr",
+	"SRC-IP-CIDR":    "source_ip_cidr",
+	"DST-PORT":       "port",
+	"SRC-PORT":       "source_port",
+	"PROCESS-NAME":   "process_name",
+	"GEOIP":          "rule_set",
+}
+
+// geoipRuleSetURL GEOIP 规则使用的远程规则集
+const geoipRuleSetURL = "https://raw.githubusercontent.com/SagerNet/sing-geoip/rule-set/geoip-%s.srs"
+
+// singboxLayoutRules 将分流规则转换为 sing-box 路由规则，连续的同类同目标规则合并为一条；
+// GEOIP 转换为远程规则集，MATCH 作为 route.final 返回
+func singboxLayoutRules(rules []Rule, schema singboxSchema) ([]map[string]interface{}, []map[string]interface{}, string) {
+	var result, ruleSets []map[string]interface{}
+	var final, lastKey, lastTarget string
+	seenSets := make(map[string]bool)
+	skipped := 0
+
+	for _, r := range rules {
+		target, ok := singboxOutboundTag(r.Target)
+		if !ok {
+			skipped++
+			continue
+		}
+		if r.Type == "MATCH" {
+			final = target
+			continue
+		}
+
+		key, ok := singboxRuleKeys[r.Type]
+		if !ok {
+			skipped++
+			continue
+		}
+
+		var value interface{} = r.Value
+		switch r.Type {
+		case "GEOIP":
+			code := strings.ToLower(r.Value)
+			if code == "lan" || code == "private" {
+				key, value = "ip_is_private", true
+				break
+			}
+			tag := "geoip-" + code
+			if !seenSets[tag] {
+				seenSets[tag] = true
+				ruleSets = append(ruleSets, map[string]interface{}{
+					"type":            "remote",
+					"tag":             tag,
+					"format":          "binary",
+					"url":             fmt.Sprintf(geoipRuleSetURL, code),
+					"download_detour": "direct",
+				})
+			}
+			value = tag
+		case "DST-PORT", "SRC-PORT":
+			port, err := strconv.Atoi(r.Value)
+			if err != nil {
+				skipped++
+				continue
+			}
+			value = port
+		}
+
+		// 与上一条规则字段和目标相同时合并
+		if key == lastKey && r.Target == lastTarget && key != "ip_is_private" {
+			last := result[len(result)-1]
+			last[key] = append(last[key].([]interface{}), value)
+			continue
+		}
+
+		rule := map[string]interface{}{}
+		if key == "ip_is_private" {
+			rule[key] = value
+		} else {
+			rule[key] = []interface{}{value}
+		}
+		switch {
+		case target == "block" && schema.ruleActions:
+			rule["action"] = "reject"
+			if r.Target == "REJECT-DROP" {
+				rule["method"] = "drop"
+			}
+		default:
+			rule["outbound"] = target
+		}
+		result = append(result, rule)
+		lastKey, lastTarget = key, r.Target
+	}
+
+	if skipped > 0 {
+		log.Printf("sing-box配置跳过 %d 条不支持的规则", skipped)
+	}
+	return result, ruleSets, final
+}
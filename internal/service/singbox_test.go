@@ -0,0 +1,105 @@
+package service
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"sublinks/internal/acl"
+	"sublinks/internal/node"
+)
+
+func TestSingBoxBuiltinPolicies(t *testing.T) {
+	template := acl.Parse("ruleset=REJECT-DROP,[]DOMAIN-SUFFIX,ads.example.com\n" +
+		"ruleset=PASS,[]DOMAIN,pass.example.com\n" +
+		"ruleset=GLOBAL,[]DOMAIN,global.example.com\n" +
+		"ruleset=🛑 拦截,[]DOMAIN,block.example.com\n" +
+		"ruleset=🚀 节点选择,[]FINAL\n" +
+		"custom_proxy_group=🚀 节点选择`select`[]GLOBAL`.*\n" +
+		"custom_proxy_group=🛑 拦截`select`[]REJECT-DROP`[]PASS`[]COMPATIBLE\n" +
+		"custom_proxy_group=🐟 漏网之鱼`select`[]PASS`[]GLOBAL\n")
+	nodes := []*node.Node{{Protocol: node.ProtocolTrojan, Name: "A", Server: "example.com", Port: 443, Password: "secret", TLS: node.TLS{Enabled: true}}}
+
+	tests := []struct {
+		version string
+		adsRule map[string]interface{}
+	}{
+		{"1.11", map[string]interface{}{"domain_suffix": []interface{}{"ads.example.com"}, "action": "reject", "method": "drop"}},
+		{"1.10", map[string]interface{}{"domain_suffix": []interface{}{"ads.example.com"}, "outbound": "block"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.version, func(t *testing.T) {
+			out, err := (&Converter{singboxVersion: tt.version}).buildSingBoxConfig(nodes, template)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var config struct {
+				Outbounds []map[string]interface{} `json:"outbounds"`
+				Route     struct {
+					Rules []map[string]interface{} `json:"rules"`
+					Final string                   `json:"final"`
+				} `json:"route"`
+			}
+			if err := json.Unmarshal([]byte(out), &config); err != nil {
+				t.Fatal(err)
+			}
+
+			// 所有引用的出站标签都必须存在
+			tags := make(map[string]bool)
+			members := make(map[string][]interface{})
+			for _, o := range config.Outbounds {
+				tag := o["tag"].(string)
+				tags[tag] = true
+				if list, ok := o["outbounds"].([]interface{}); ok {
+					members[tag] = list
+				}
+			}
+			var refs []string
+			for _, list := range members {
+				for _, m := range list {
+					refs = append(refs, m.(string))
+				}
+			}
+			for _, r := range config.Route.Rules {
+				if o, ok := r["outbound"].(string); ok {
+					refs = append(refs, o)
+				}
+			}
+			for _, ref := range append(refs, config.Route.Final) {
+				if !tags[ref] {
+					t.Errorf("引用了不存在的出站 %q", ref)
+				}
+			}
+
+			wantMembers := map[string][]interface{}{
+				"🚀 节点选择": {"A"},
+				"🛑 拦截":   {"block", "direct"},
+				"🐟 漏网之鱼": {"direct"},
+			}
+			for tag, want := range wantMembers {
+				if !reflect.DeepEqual(members[tag], want) {
+					t.Errorf("代理组 %s 的成员为 %v，期望 %v", tag, members[tag], want)
+				}
+			}
+
+			var found bool
+			for _, r := range config.Route.Rules {
+				if _, ok := r["domain_suffix"]; ok {
+					found = true
+					if !reflect.DeepEqual(r, tt.adsRule) {
+						t.Errorf("REJECT-DROP 规则为 %v，期望 %v", r, tt.adsRule)
+					}
+				}
+				if d, ok := r["domain"].([]interface{}); ok && len(d) > 0 && d[0] != "block.example.com" {
+					t.Errorf("PASS 和 GLOBAL 规则应被跳过，得到 %v", r)
+				}
+			}
+			if !found {
+				t.Error("缺少 REJECT-DROP 规则")
+			}
+			if config.Route.Final != "🚀 节点选择" {
+				t.Errorf("route.final 为 %q", config.Route.Final)
+			}
+		})
+	}
+}
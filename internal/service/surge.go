@@ -0,0 +1,249 @@
+package service
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"sublinks/internal/acl"
+	"sublinks/internal/node"
+)
+
+// lineParams 逐项拼接 Surge/Loon/QuanX 代理行中的参数
+type lineParams []string
+
+func (p *lineParams) add(items ...string) {
+	*p = append(*p, items...)
+}
+
+// set 添加 key=value 参数，值为空时忽略
+func (p *lineParams) set(key, value string) {
+	if value != "" {
+		*p = append(*p, key+"="+value)
+	}
+}
+
+// setBool 添加值为 true 的布尔参数
+func (p *lineParams) setBool(key string, value bool) {
+	if value {
+		*p = append(*p, key+"=true")
+	}
+}
+
+// quoteValue 用双引号包裹参数值，值本身包含双引号时无法表示
+func quoteValue(v string) (string, bool) {
+	if strings.ContainsAny(v, "\"\r\n") {
+		return "", false
+	}
+	return `"` + v + `"`, true
+}
+
+// buildSurgeConfig 根据节点列表构建Surge配置，managedURL 非空时添加托管配置头
+func (c *Converter) buildSurgeConfig(nodes []*node.Node, t *acl.Template, managedURL string) (string, error) {
+	var proxies, names []string
+	used := newLineNames()
+	skipped := 0
+	for i, n := range nodes {
+		params, ok := surgeProxy(n)
+		if !ok {
+			skipped++
+			continue
+		}
+		name := used.name(proxyName(n, i))
+		proxies = append(proxies, name+" = "+strings.Join(params, ", "))
+		names = append(names, name)
+	}
+
+	if skipped > 0 {
+		log.Printf("Surge配置跳过 %d 个不支持的节点", skipped)
+	}
+	if len(names) == 0 {
+		return "", ErrNoNodes
+	}
+	log.Printf("生成Surge配置，共 %d 个节点", len(names))
+
+	var b strings.Builder
+	if managedURL != "" {
+		fmt.Fprintf(&b, "#!MANAGED-CONFIG %s interval=%d strict=false\n\n", managedURL, c.updateTime*3600)
+	}
+
+	b.WriteString("[General]\n")
+	b.WriteString("loglevel = notify\n")
+	b.WriteString("skip-proxy = 127.0.0.1, 192.168.0.0/16, 10.0.0.0/8, 172.16.0.0/12, 100.64.0.0/10, localhost, *.local\n")
+	b.WriteString("dns-server = system, 223.5.5.5, 119.29.29.29\n")
+	fmt.Fprintf(&b, "proxy-test-url = %s\n\n", testURL)
+
+	b.WriteString("[Proxy]\n")
+	for _, line := range proxies {
+		b.WriteString(line + "\n")
+	}
+
+	layout := c.layout(t, names)
+	b.WriteString("\n[Proxy Group]\n")
+	for _, g := range layout.Groups {
+		b.WriteString(lineGroup(g, ", ") + "\n")
+	}
+
+	b.WriteString("\n[Rule]\n")
+	for _, r := range layout.Rules {
+		if line, ok := surgeRule(r); ok {
+			b.WriteString(line + "\n")
+		}
+	}
+	return b.String(), nil
+}
+
+// surgeRuleTypes Surge 支持的规则类型，值为Surge中的名称
+var surgeRuleTypes = map[string]string{
+	"DOMAIN":         "DOMAIN",
+	"DOMAIN-SUFFIX":  "DOMAIN-SUFFIX",
+	"DOMAIN-KEYWORD": "DOMAIN-KEYWORD",
+	"IP-CIDR":        "IP-CIDR",
+	"IP-CIDR6":       "IP-CIDR6",
+	"GEOIP":          "GEOIP",
+	"USER-AGENT":     "USER-AGENT",
+	"URL-REGEX":      "URL-REGEX",
+	"PROCESS-NAME":   "PROCESS-NAME",
+	"DST-PORT":       "DEST-PORT",
+	"SRC-IP-CIDR":    "SRC-IP",
+}
+
+// surgeRule 生成Surge规则行，不支持的规则类型返回false
+func surgeRule(r Rule) (string, bool) {
+	target := lineProxyName(r.Target)
+	if r.Type == "MATCH" {
+		return "FINAL," + target + ",dns-failed", true
+	}
+	typ, ok := surgeRuleTypes[r.Type]
+	if !ok {
+		return "", false
+	}
+	line := typ + "," + r.Value + "," + target
+	if r.NoResolve {
+		line += ",no-resolve"
+	}
+	return line, true
+}
+
+// lineGroup 生成Surge/Loon格式的代理组行
+func lineGroup(g ProxyGroup, sep string) string {
+	items := lineParams{g.Type}
+	for _, p := range g.Proxies {
+		items.add(lineProxyName(p))
+	}
+	if g.Type != "select" {
+		items.set("url", g.URL)
+		if g.Interval > 0 {
+			items.set("interval", strconv.Itoa(g.Interval))
+		}
+		if g.Tolerance > 0 && g.Type == "url-test" {
+			items.set("tolerance", strconv.Itoa(g.Tolerance))
+		}
+	}
+	return lineProxyName(g.Name) + " = " + strings.Join(items, sep)
+}
+
+// surgeProxy 生成Surge代理行的参数部分，不支持的节点返回false
+func surgeProxy(n *node.Node) ([]string, bool) {
+	p := lineParams{}
+	server, port := n.Server, strconv.Itoa(n.Port)
+
+	switch n.Protocol {
+	case node.ProtocolShadowsocks:
+		password, ok := quoteValue(n.Password)
+		if !ok {
+			return nil, false
+		}
+		p.add("ss", server, port)
+		p.set("encrypt-method", n.Cipher)
+		p.set("password", password)
+		switch n.Plugin {
+		case "":
+		case "obfs-local", "simple-obfs", "obfs":
+			opts := n.PluginOptions()
+			p.set("obfs", opts["obfs"])
+			p.set("obfs-host", opts["obfs-host"])
+		default:
+			return nil, false
+		}
+		p.setBool("udp-relay", true)
+	case node.ProtocolVMess:
+		p.add("vmess", server, port)
+		p.set("username", n.UUID)
+		p.setBool("vmess-aead", n.AlterID == 0)
+		if !setSurgeTransport(&p, n) {
+			return nil, false
+		}
+	case node.ProtocolTrojan:
+		password, ok := quoteValue(n.Password)
+		if !ok {
+			return nil, false
+		}
+		p.add("trojan", server, port)
+		p.set("password", password)
+		if !setSurgeTransport(&p, n) {
+			return nil, false
+		}
+	case node.ProtocolHysteria2:
+		password, ok := quoteValue(n.Password)
+		if !ok || n.Obfs != "" {
+			return nil, false
+		}
+		p.add("hysteria2", server, port)
+		p.set("password", password)
+		if n.Ports != "" {
+			p.set("port-hopping", `"`+strings.ReplaceAll(n.Ports, ",", ";")+`"`)
+		}
+		if n.DownMbps > 0 {
+			p.set("download-bandwidth", strconv.Itoa(n.DownMbps))
+		}
+		setSurgeTLS(&p, n.TLS)
+	case node.ProtocolTUIC:
+		password, ok := quoteValue(n.Password)
+		if !ok {
+			return nil, false
+		}
+		p.add("tuic-v5", server, port)
+		p.set("password", password)
+		p.set("uuid", n.UUID)
+		p.set("alpn", strings.Join(n.TLS.ALPN, ","))
+		setSurgeTLS(&p, n.TLS)
+	default:
+		return nil, false
+	}
+	return p, true
+}
+
+// setSurgeTransport 设置TLS与WebSocket参数，Surge 仅支持 tcp 和 ws 传输
+func setSurgeTransport(p *lineParams, n *node.Node) bool {
+	switch n.Transport.Type {
+	case "", "tcp":
+		if n.Transport.HeaderType == "http" {
+			return false
+		}
+	case "ws":
+		p.setBool("ws", true)
+		p.set("ws-path", n.Transport.Path)
+		if n.Transport.Host != "" {
+			p.set("ws-headers", "Host:"+n.Transport.Host)
+		}
+	default:
+		return false
+	}
+
+	if n.TLS.Enabled {
+		if n.TLS.Reality != nil {
+			return false
+		}
+		p.setBool("tls", n.Protocol != node.ProtocolTrojan)
+		setSurgeTLS(p, n.TLS)
+	}
+	return true
+}
+
+// setSurgeTLS 设置SNI与证书校验参数
+func setSurgeTLS(p *lineParams, t node.TLS) {
+	p.set("sni", t.SNI)
+	p.setBool("skip-cert-verify", t.Insecure)
+}
@@ -0,0 +1,88 @@
+package service
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// userinfo_source 的特殊取值
+const (
+	// UserInfoSum 汇总所有订阅源的流量，到期时间取最早的一个
+	UserInfoSum = ""
+	// UserInfoNone 不向客户端返回流量信息
+	UserInfoNone = "none"
+)
+
+// UserInfo 订阅的流量和到期信息，对应 Subscription-Userinfo 响应头，流量单位为字节
+type UserInfo struct {
+	Upload   int64 `json:"upload"`
+	Download int64 `json:"download"`
+	Total    int64 `json:"total"`
+	// Expire 到期时间（Unix 秒），为0时表示不限期
+	Expire int64 `json:"expire,omitempty"`
+}
+
+// ParseUserInfo 解析 "upload=1; download=2; total=3; expire=4" 格式的响应头，没有任何有效字段时返回nil
+func ParseUserInfo(header string) *UserInfo {
+	var info UserInfo
+	found := false
+	for _, item := range strings.Split(header, ";") {
+		kv := strings.SplitN(strings.TrimSpace(item), "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		// 部分机场返回浮点数
+		value, err := strconv.ParseFloat(strings.TrimSpace(kv[1]), 64)
+		if err != nil {
+			continue
+		}
+
+		switch strings.ToLower(strings.TrimSpace(kv[0])) {
+		case "upload":
+			info.Upload = int64(value)
+		case "download":
+			info.Download = int64(value)
+		case "total":
+			info.Total = int64(value)
+		case "expire":
+			info.Expire = int64(value)
+		default:
+			continue
+		}
+		found = true
+	}
+	if !found {
+		return nil
+	}
+	return &info
+}
+
+// String 编码为 Subscription-Userinfo 响应头
+func (u *UserInfo) String() string {
+	s := fmt.Sprintf("upload=%d; download=%d; total=%d", u.Upload, u.Download, u.Total)
+	if u.Expire > 0 {
+		s += fmt.Sprintf("; expire=%d", u.Expire)
+	}
+	return s
+}
+
+// SumUserInfo 汇总多个订阅源的流量信息，到期时间取最早的一个，没有任何信息时返回nil
+func SumUserInfo(infos []*UserInfo) *UserInfo {
+	var sum *UserInfo
+	for _, info := range infos {
+		if info == nil {
+			continue
+		}
+		if sum == nil {
+			sum = &UserInfo{}
+		}
+		sum.Upload += info.Upload
+		sum.Download += info.Download
+		sum.Total += info.Total
+		if info.Expire > 0 && (sum.Expire == 0 || info.Expire < sum.Expire) {
+			sum.Expire = info.Expire
+		}
+	}
+	return sum
+}
@@ -0,0 +1,272 @@
+package share
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TokenPrefix 分享令牌的前缀，用于和普通令牌区分
+const TokenPrefix = "s."
+
+var (
+	// ErrNotShareToken 令牌不是分享令牌，应按普通令牌验证
+	ErrNotShareToken = errors.New("不是分享令牌")
+	// ErrDisabled 未配置 share_secret
+	ErrDisabled = errors.New("未配置 share_secret，分享链接已禁用")
+	ErrInvalid  = errors.New("分享链接无效")
+	ErrExpired  = errors.New("分享链接已过期")
+	ErrRevoked  = errors.New("分享链接已撤销")
+	ErrUsedUp   = errors.New("分享链接使用次数已用完")
+	ErrNotFound = errors.New("分享链接不存在")
+)
+
+// Claims 分享令牌中签名保护的内容
+type Claims struct {
+	ID string `json:"id"`
+	// Expires 过期时间（Unix 秒）
+	Expires int64 `json:"exp"`
+	// Profile 只能使用该用户的订阅源和过滤规则，为空时使用全部订阅源
+	Profile string `json:"profile,omitempty"`
+	// MaxUses 最多可获取订阅的次数，为0时不限制
+	MaxUses int `json:"max_uses,omitempty"`
+}
+
+// Link 已签发的分享链接及使用情况
+type Link struct {
+	Claims
+	Created int64 `json:"created"`
+	Uses    int   `json:"uses"`
+	Revoked bool  `json:"revoked"`
+}
+
+type storeFile struct {
+	Links []Link `json:"links"`
+	// Revoked 已撤销的链接ID，即使签发记录丢失也会拒绝
+	Revoked []string `json:"revoked"`
+}
+
+// Store 签发和验证分享链接，签发记录、使用次数和撤销列表保存在文件中
+type Store struct {
+	secret []byte
+	file   string
+
+	mutex   sync.Mutex
+	links   []Link
+	revoked map[string]bool
+}
+
+// NewStore 创建分享链接存储，secret 为空时禁用分享链接；文件不存在时视为没有记录。
+// 文件无法读取或解析时返回错误，此时不能继续使用，否则下次保存会清空撤销列表
+func NewStore(secret, file string) (*Store, error) {
+	s := &Store{
+		secret:  []byte(secret),
+		file:    file,
+		revoked: make(map[string]bool),
+	}
+
+	data, err := os.ReadFile(file)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var f storeFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("解析 %s 失败: %w", file, err)
+	}
+	s.links = f.Links
+	for _, id := range f.Revoked {
+		s.revoked[id] = true
+	}
+	return s, nil
+}
+
+// Enabled 是否配置了签名密钥
+func (s *Store) Enabled() bool {
+	return len(s.secret) > 0
+}
+
+// Issue 签发分享链接，返回令牌和签发记录
+func (s *Store) Issue(expires time.Time, profile string, maxUses int) (string, Link, error) {
+	if !s.Enabled() {
+		return "", Link{}, ErrDisabled
+	}
+
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return "", Link{}, err
+	}
+	link := Link{
+		Claims: Claims{
+			ID:      hex.EncodeToString(id),
+			Expires: expires.Unix(),
+			Profile: profile,
+			MaxUses: maxUses,
+		},
+		Created: time.Now().Unix(),
+	}
+
+	payload, err := json.Marshal(link.Claims)
+	if err != nil {
+		return "", Link{}, err
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	token := TokenPrefix + encoded + "." + base64.RawURLEncoding.EncodeToString(s.sign(encoded))
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	next := append(append([]Link{}, s.links...), link)
+	if err := s.save(next); err != nil {
+		return "", Link{}, err
+	}
+	s.links = next
+	return token, link, nil
+}
+
+// Verify 验证分享令牌的签名、有效期、撤销状态和使用次数，不计入使用次数。
+// 不是分享令牌时返回 ErrNotShareToken
+func (s *Store) Verify(token string) (Claims, error) {
+	if !strings.HasPrefix(token, TokenPrefix) {
+		return Claims{}, ErrNotShareToken
+	}
+	if !s.Enabled() {
+		return Claims{}, ErrDisabled
+	}
+
+	parts := strings.Split(strings.TrimPrefix(token, TokenPrefix), ".")
+	if len(parts) != 2 {
+		return Claims{}, ErrInvalid
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil || !hmac.Equal(signature, s.sign(parts[0])) {
+		return Claims{}, ErrInvalid
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return Claims{}, ErrInvalid
+	}
+	var claims Claims
+	if err := json.Unmarshal(payload, &claims); err != nil || claims.ID == "" {
+		return Claims{}, ErrInvalid
+	}
+	if time.Now().Unix() >= claims.Expires {
+		return Claims{}, ErrExpired
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.revoked[claims.ID] {
+		return Claims{}, ErrRevoked
+	}
+
+	index := s.find(claims.ID)
+	if claims.MaxUses > 0 {
+		// 没有签发记录时无法统计次数，按已用完处理
+		if index < 0 || s.links[index].Uses >= claims.MaxUses {
+			return Claims{}, ErrUsedUp
+		}
+	}
+	return claims, nil
+}
+
+// Consume 计入一次使用，应在订阅内容成功生成后调用。验证后次数已被其他请求用完时返回 ErrUsedUp
+func (s *Store) Consume(claims Claims) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.revoked[claims.ID] {
+		return ErrRevoked
+	}
+	index := s.find(claims.ID)
+	if index < 0 {
+		if claims.MaxUses > 0 {
+			return ErrUsedUp
+		}
+		return nil
+	}
+	if claims.MaxUses > 0 && s.links[index].Uses >= claims.MaxUses {
+		return ErrUsedUp
+	}
+
+	next := append([]Link{}, s.links...)
+	next[index].Uses++
+	if err := s.save(next); err != nil {
+		return err
+	}
+	s.links = next
+	return nil
+}
+
+// Revoke 撤销分享链接，撤销后立即失效
+func (s *Store) Revoke(id string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	index := s.find(id)
+	if index < 0 {
+		return ErrNotFound
+	}
+
+	next := append([]Link{}, s.links...)
+	next[index].Revoked = true
+	s.revoked[id] = true
+	if err := s.save(next); err != nil {
+		delete(s.revoked, id)
+		return err
+	}
+	s.links = next
+	return nil
+}
+
+// Links 返回所有签发记录
+func (s *Store) Links() []Link {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return append([]Link{}, s.links...)
+}
+
+func (s *Store) sign(payload string) []byte {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(payload))
+	return mac.Sum(nil)
+}
+
+func (s *Store) find(id string) int {
+	for i, link := range s.links {
+		if link.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// save 写入存储文件，调用方需持有 mutex
+func (s *Store) save(links []Link) error {
+	f := storeFile{Links: links, Revoked: make([]string, 0, len(s.revoked))}
+	for id := range s.revoked {
+		f.Revoked = append(f.Revoked, id)
+	}
+	sort.Strings(f.Revoked)
+
+	data, err := json.MarshalIndent(f, "", "    ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(s.file, data, 0600)
+}
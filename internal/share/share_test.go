@@ -0,0 +1,157 @@
+package share
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newStore(t *testing.T, secret string) *Store {
+	t.Helper()
+	s, err := NewStore(secret, filepath.Join(t.TempDir(), "shares.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestVerifySignature(t *testing.T) {
+	s := newStore(t, "secret")
+	token, link, err := s.Issue(time.Now().Add(time.Hour), "alice", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	claims, err := s.Verify(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims != link.Claims {
+		t.Errorf("得到 %+v，期望 %+v", claims, link.Claims)
+	}
+
+	payload, signature, _ := strings.Cut(strings.TrimPrefix(token, TokenPrefix), ".")
+	other := newStore(t, "other")
+	forged, _, err := other.Issue(time.Now().Add(time.Hour), "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, forgedSignature, _ := strings.Cut(strings.TrimPrefix(forged, TokenPrefix), ".")
+
+	tests := []struct {
+		name  string
+		token string
+		want  error
+	}{
+		{"普通令牌", "owner", ErrNotShareToken},
+		{"缺少签名", TokenPrefix + payload, ErrInvalid},
+		{"签名错误", TokenPrefix + payload + "." + forgedSignature, ErrInvalid},
+		{"签名不是 base64", TokenPrefix + payload + ".!!", ErrInvalid},
+		{"内容被修改", TokenPrefix + strings.ToUpper(payload) + "." + signature, ErrInvalid},
+		{"其他密钥签发", forged, ErrInvalid},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := s.Verify(tt.token); !errors.Is(err, tt.want) {
+				t.Errorf("得到 %v，期望 %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerifyDisabled(t *testing.T) {
+	s := newStore(t, "")
+	if _, _, err := s.Issue(time.Now().Add(time.Hour), "", 0); !errors.Is(err, ErrDisabled) {
+		t.Errorf("签发得到 %v，期望 %v", err, ErrDisabled)
+	}
+	if _, err := s.Verify(TokenPrefix + "a.b"); !errors.Is(err, ErrDisabled) {
+		t.Errorf("验证得到 %v，期望 %v", err, ErrDisabled)
+	}
+}
+
+func TestVerifyExpired(t *testing.T) {
+	s := newStore(t, "secret")
+	token, _, err := s.Issue(time.Now().Add(-time.Second), "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Verify(token); !errors.Is(err, ErrExpired) {
+		t.Errorf("得到 %v，期望 %v", err, ErrExpired)
+	}
+}
+
+func TestConsumeMaxUses(t *testing.T) {
+	s := newStore(t, "secret")
+	token, link, err := s.Issue(time.Now().Add(time.Hour), "", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 验证不计入使用次数
+	for i := 0; i < 3; i++ {
+		if _, err := s.Verify(token); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if uses := s.Links()[0].Uses; uses != 0 {
+		t.Errorf("验证后使用次数为 %d，期望 0", uses)
+	}
+
+	for i := 0; i < 2; i++ {
+		claims, err := s.Verify(token)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := s.Consume(claims); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := s.Verify(token); !errors.Is(err, ErrUsedUp) {
+		t.Errorf("验证得到 %v，期望 %v", err, ErrUsedUp)
+	}
+	if err := s.Consume(link.Claims); !errors.Is(err, ErrUsedUp) {
+		t.Errorf("计入使用得到 %v，期望 %v", err, ErrUsedUp)
+	}
+
+	// 使用次数保存在文件中
+	reloaded, err := NewStore("secret", s.file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := reloaded.Verify(token); !errors.Is(err, ErrUsedUp) {
+		t.Errorf("重新加载后得到 %v，期望 %v", err, ErrUsedUp)
+	}
+}
+
+func TestRevoke(t *testing.T) {
+	s := newStore(t, "secret")
+	token, link, err := s.Issue(time.Now().Add(time.Hour), "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Revoke(link.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Verify(token); !errors.Is(err, ErrRevoked) {
+		t.Errorf("验证得到 %v，期望 %v", err, ErrRevoked)
+	}
+	if err := s.Consume(link.Claims); !errors.Is(err, ErrRevoked) {
+		t.Errorf("计入使用得到 %v，期望 %v", err, ErrRevoked)
+	}
+	if err := s.Revoke("missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("撤销不存在的链接得到 %v，期望 %v", err, ErrNotFound)
+	}
+}
+
+func TestNewStoreCorruptFile(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "shares.json")
+	if err := os.WriteFile(file, []byte("{bad"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if s, err := NewStore("secret", file); err == nil || s != nil {
+		t.Errorf("得到 %v, %v，期望返回错误", s, err)
+	}
+}